@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// headingAnchor is the explicit anchor put at the start of a translated
+// heading so it keeps the English slug: `## <a id="configuration" />配置`.
+// It is HTML because MDX reads a trailing {#configuration} as an expression.
+func headingAnchor(id string) string {
+	return `<a id="` + id + `" />`
+}
+
+// anchorTagRe matches a headingAnchor at the start of a heading's text.
+var anchorTagRe = regexp.MustCompile(`^<a\s+id="([^"]+)"\s*/>`)
+
+// explicitHeadingID returns the ID a heading sets itself, as {#id} or as a
+// leading headingAnchor.
+func explicitHeadingID(heading *ast.Heading, source []byte) (string, bool) {
+	if value, ok := heading.AttributeString("id"); ok {
+		if raw, ok := value.([]byte); ok {
+			return string(raw), true
+		}
+	}
+	if start, stop, ok := headingContent(heading); ok {
+		if match := anchorTagRe.FindSubmatch(source[start:stop]); match != nil {
+			return string(match[1]), true
+		}
+	}
+	return "", false
+}
+
+// headingText is the plain text of a heading, code spans included.
+func headingText(heading ast.Node, source []byte) string {
+	var out strings.Builder
+	_ = ast.Walk(heading, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Text:
+			out.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() {
+				out.WriteByte(' ')
+			}
+		case *ast.String:
+			out.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return out.String()
+}
+
+// headingSlug derives a heading anchor the way the docs site does
+// (GitHub-style): lower-cased, punctuation dropped, spaces to hyphens.
+// Letters and digits of any script are kept.
+func headingSlug(heading string) string {
+	var out strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case r == ' ':
+			out.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// parseHeadings parses body with explicit heading IDs enabled.
+func parseHeadings(source []byte) ast.Node {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithHeadingAttribute()),
+	)
+	return md.Parser().Parse(text.NewReader(source))
+}
+
+// headingIDs assigns heading anchors in document order the way the docs site
+// does: an explicit ID is used as is, otherwise the slug of the heading
+// text, suffixed -1, -2, … when an earlier heading already took it. Explicit
+// IDs that an earlier heading already took are collected as collisions.
+type headingIDs struct {
+	used       map[string]bool
+	collisions []string
+}
+
+func newHeadingIDs() *headingIDs {
+	return &headingIDs{used: map[string]bool{}}
+}
+
+func (h *headingIDs) next(heading *ast.Heading, source []byte) (id string, explicit bool) {
+	if id, ok := explicitHeadingID(heading, source); ok {
+		if h.used[id] {
+			h.collisions = append(h.collisions, id)
+		}
+		h.used[id] = true
+		return id, true
+	}
+	base := headingSlug(headingText(heading, source))
+	id = base
+	for n := 1; h.used[id]; n++ {
+		id = base + "-" + strconv.Itoa(n)
+	}
+	h.used[id] = true
+	return id, false
+}
+
+// headingContent returns the byte range of a heading's text, excluding the
+// marker and any trailing {#id}.
+func headingContent(heading *ast.Heading) (int, int, bool) {
+	lines := heading.Lines()
+	if lines.Len() == 0 {
+		return 0, 0, false
+	}
+	return lines.At(0).Start, lines.At(lines.Len() - 1).Stop, true
+}
+
+// anchorHeadings gives the headings of a translated body the anchors of the
+// source headings they translate, paired by position. Headings that already
+// have an explicit ID are left alone, as is a body whose headings don't line
+// up with the source.
+func anchorHeadings(sourceBody, translatedBody string) string {
+	source := []byte(sourceBody)
+	sourceIDs := newHeadingIDs()
+	var want []string
+	_ = ast.Walk(parseHeadings(source), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		id, explicit := sourceIDs.next(heading, source)
+		if explicit {
+			id = ""
+		}
+		want = append(want, id)
+		return ast.WalkSkipChildren, nil
+	})
+
+	translated := []byte(translatedBody)
+	var edits []htmlReplacement
+	index := 0
+	_ = ast.Walk(parseHeadings(translated), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if index < len(want) && want[index] != "" {
+			start, _, ok := headingContent(heading)
+			if _, explicit := explicitHeadingID(heading, translated); ok && !explicit {
+				edits = append(edits, htmlReplacement{Start: start, Stop: start, Value: headingAnchor(want[index])})
+			}
+		}
+		index++
+		return ast.WalkSkipChildren, nil
+	})
+	if index != len(want) || len(edits) == 0 {
+		return translatedBody
+	}
+	return applyHTMLReplacements(translatedBody, edits)
+}
+
+// validateHeadingIDs reports headings in body whose explicit anchors collide
+// with an earlier heading's.
+func validateHeadingIDs(body string) error {
+	source := []byte(body)
+	ids := newHeadingIDs()
+	_ = ast.Walk(parseHeadings(source), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := n.(*ast.Heading); ok && entering {
+			ids.next(heading, source)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(ids.collisions) > 0 {
+		return fmt.Errorf("duplicate heading anchors: %s", strings.Join(ids.collisions, ", "))
+	}
+	return nil
+}
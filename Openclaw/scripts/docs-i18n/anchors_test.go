@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const anchorSource = "# Setup `openclaw`\n\nIntro.\n\n## Setup\n\n## `openclaw.json`\n\n## Keep {#custom}\n"
+
+func TestSegmentAnchors(t *testing.T) {
+	segments, err := extractSegments(anchorSource, "start/setup.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range segments {
+		segments[i].Translated = "译" + strings.TrimSpace(segments[i].Text)
+	}
+	want := "# <a id=\"setup-openclaw\" />译Setup`openclaw`\n\n译Intro.\n\n## <a id=\"setup\" />译Setup\n\n## `openclaw.json`\n\n## 译Keep {#custom}\n"
+	if got := applyTranslations(anchorSource, segments); got != want {
+		t.Fatalf("applyTranslations:\n%s\nwant:\n%s", got, want)
+	}
+	if got := headingSlugs(want); strings.Join(got, ",") != "setup-openclaw,setup,openclawjson,custom" {
+		t.Errorf("headingSlugs = %v", got)
+	}
+}
+
+func TestAnchorHeadings(t *testing.T) {
+	translated := "# 设置 `openclaw`\n\n介绍。\n\n## <a id=\"setup\" />设置\n\n## `openclaw.json`\n\n## 保留 {#custom}\n"
+	want := "# <a id=\"setup-openclaw\" />设置 `openclaw`\n\n介绍。\n\n## <a id=\"setup\" />设置\n\n## <a id=\"openclawjson\" />`openclaw.json`\n\n## 保留 {#custom}\n"
+	if got := anchorHeadings(anchorSource, translated); got != want {
+		t.Fatalf("anchorHeadings:\n%s\nwant:\n%s", got, want)
+	}
+	if err := validateHeadingIDs(want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Headings that don't line up with the source are left alone.
+	if got := anchorHeadings(anchorSource, "# 设置\n"); got != "# 设置\n" {
+		t.Errorf("mismatched headings were anchored:\n%s", got)
+	}
+}
+
+func TestValidateHeadingIDs(t *testing.T) {
+	err := validateHeadingIDs("## Setup\n\n## <a id=\"setup\" />设置\n\n## 配置 {#config}\n\n## <a id=\"config\" />其他\n")
+	if err == nil || !strings.Contains(err.Error(), "setup, config") {
+		t.Fatalf("err = %v, want setup and config collisions", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBatchSize     = 25
+	segmentBatchMaxChars = 8000
+	segmentBatchTagEnd   = "</seg>"
+)
+
+var segmentBatchRe = regexp.MustCompile(`(?s)<seg id="(\d+)">(.*?)</seg>`)
+
+type segmentOptions struct {
+	// BatchSize is the maximum number of uncached segments packed into one
+	// request; values below 2 translate every segment on its own.
+	BatchSize int
+	// References is how many similar translation memory entries are sent as
+	// reference translations with each uncached segment; 0 sends none.
+	References int
+	// Glossary checks freshly translated segments; nil skips the check.
+	Glossary *glossaryChecker
+	// Links points doc links at translated pages; nil leaves them alone.
+	Links *linkLocalizer
+	// Components lists the component attributes to translate; nil
+	// translates none.
+	Components componentAttrs
+	// CodeComments lists the fenced code blocks whose line comments are
+	// translated; nil translates none.
+	CodeComments codeCommentPolicy
+	// Sources shares parsed source pages between languages; nil parses
+	// every page on its own.
+	Sources *sourceCache
+	// QA back-translates and checks the translated segments; nil skips it.
+	QA *qaChecker
+}
+
+type batchItem struct {
+	segment      *Segment
+	prefix       string
+	suffix       string
+	masked       string
+	placeholders []string
+	mapping      map[string]string
+}
+
+// translateSegments fills Translated for every pending segment, packing them
+// into tagged multi-segment requests when batching is enabled.
+func translateSegments(ctx context.Context, translator Translator, pending []*Segment, srcLang, tgtLang string, opts segmentOptions) error {
+	if opts.BatchSize < 2 {
+		for _, seg := range pending {
+			translated, err := translator.Translate(ctx, seg.Text, srcLang, tgtLang, promptHints{References: seg.References})
+			if err != nil {
+				return err
+			}
+			seg.Translated = translated
+		}
+		return nil
+	}
+	for _, batch := range planSegmentBatches(pending, opts.BatchSize, segmentBatchMaxChars) {
+		if err := translateSegmentBatch(ctx, translator, batch, srcLang, tgtLang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func planSegmentBatches(pending []*Segment, maxSegments, maxChars int) [][]*Segment {
+	var batches [][]*Segment
+	var current []*Segment
+	size := 0
+	for _, seg := range pending {
+		if len(current) > 0 && (len(current) >= maxSegments || size+len(seg.Text) > maxChars) {
+			batches = append(batches, current)
+			current = nil
+			size = 0
+		}
+		current = append(current, seg)
+		size += len(seg.Text)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// translateSegmentBatch sends one tagged request for the batch and falls back
+// to per-segment calls for any block that is missing or fails validation. A
+// reply cut short at the model's output limit splits the batch in two.
+func translateSegmentBatch(ctx context.Context, translator Translator, batch []*Segment, srcLang, tgtLang string) error {
+	items := make([]batchItem, 0, len(batch))
+	var seed strings.Builder
+	for _, seg := range batch {
+		seed.WriteString(seg.Text)
+	}
+	state := NewPlaceholderState(seed.String())
+	var request strings.Builder
+	for _, seg := range batch {
+		prefix, core, suffix := splitWhitespace(seg.Text)
+		if core == "" {
+			seg.Translated = seg.Text
+			continue
+		}
+		item := batchItem{segment: seg, prefix: prefix, suffix: suffix, mapping: map[string]string{}}
+		item.masked = maskMarkdown(core, state.Next, &item.placeholders, item.mapping)
+		items = append(items, item)
+		fmt.Fprintf(&request, "<seg id=\"%d\">%s%s\n", len(items), item.masked, segmentBatchTagEnd)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	var blocks map[int]string
+	if len(items) > 1 {
+		response, err := translator.TranslateRaw(ctx, request.String(), srcLang, tgtLang, promptHints{References: batchReferences(items)})
+		switch {
+		case err == nil:
+			blocks = parseSegmentBatch(response)
+		case errors.Is(err, errOutputTruncated):
+			emitFileEvent(ctx, runEvent{Event: "validation_failure", Error: fmt.Sprintf("batch of %d segments truncated; splitting it", len(items))})
+			half := len(batch) / 2
+			if err := translateSegmentBatch(ctx, translator, batch[:half], srcLang, tgtLang); err != nil {
+				return err
+			}
+			return translateSegmentBatch(ctx, translator, batch[half:], srcLang, tgtLang)
+		case !errors.Is(err, errEmptyTranslation):
+			return err
+		}
+	}
+
+	for index, item := range items {
+		translated, ok := blocks[index+1]
+		if ok {
+			translated, ok = unmaskBatchBlock(translated, item)
+		}
+		if !ok {
+			if blocks != nil {
+				emitFileEvent(ctx, runEvent{Event: "validation_failure", Error: fmt.Sprintf("batch segment %d missing or damaged; translating it on its own", index+1)})
+			}
+			fallback, err := translator.Translate(ctx, item.segment.Text, srcLang, tgtLang, promptHints{References: item.segment.References})
+			if err != nil {
+				return err
+			}
+			item.segment.Translated = fallback
+			continue
+		}
+		item.segment.Translated = item.prefix + translated + item.suffix
+	}
+	return nil
+}
+
+// batchReferences merges the references of the batch's segments, dropping
+// repeats and capping the total so the context stays small.
+func batchReferences(items []batchItem) []TMEntry {
+	var references []TMEntry
+	seen := map[string]bool{}
+	for _, item := range items {
+		for _, entry := range item.segment.References {
+			if seen[entry.TextHash] || len(references) >= fuzzyMaxBatchReferences {
+				continue
+			}
+			seen[entry.TextHash] = true
+			references = append(references, entry)
+		}
+	}
+	return references
+}
+
+func parseSegmentBatch(response string) map[int]string {
+	blocks := map[int]string{}
+	for _, match := range segmentBatchRe.FindAllStringSubmatch(response, -1) {
+		id, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if _, dup := blocks[id]; dup {
+			// A repeated id means the model lost track; trust neither copy.
+			blocks[id] = ""
+			continue
+		}
+		blocks[id] = match[2]
+	}
+	return blocks
+}
+
+func unmaskBatchBlock(block string, item batchItem) (string, bool) {
+	translated := strings.TrimSpace(block)
+	if translated == "" || strings.Contains(translated, "<seg") {
+		return "", false
+	}
+	if err := validatePlaceholders(translated, item.placeholders); err != nil {
+		return "", false
+	}
+	if strings.Count(translated, "__OC_I18N_") != len(item.placeholders) {
+		return "", false
+	}
+	return unmaskMarkdown(translated, item.placeholders, item.mapping), true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dropPlaceholderTranslator answers batch requests through the fake backend
+// but strips the placeholders from the second block.
+type dropPlaceholderTranslator struct {
+	*FakeTranslator
+}
+
+func (t dropPlaceholderTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	out, err := t.FakeTranslator.TranslateRaw(ctx, text, srcLang, tgtLang, hints)
+	if err != nil {
+		return "", err
+	}
+	blocks := segmentBatchRe.FindAllStringSubmatch(out, -1)
+	if len(blocks) < 2 {
+		return out, nil
+	}
+	broken := placeholderRe.ReplaceAllString(blocks[1][2], "")
+	return strings.Replace(out, blocks[1][2], broken, 1), nil
+}
+
+func TestSegmentBatchFallsBackForInvalidBlocks(t *testing.T) {
+	segments := []*Segment{
+		{Text: "Plain prose."},
+		{Text: "Run `openclaw doctor` first. "},
+		{Text: "See [docs](/start) too."},
+	}
+	translator := dropPlaceholderTranslator{NewFakeTranslator()}
+	if err := translateSegments(context.Background(), translator, segments, "en", "zh-CN", segmentOptions{BatchSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if got := translator.Calls(); got != 2 {
+		t.Fatalf("calls = %d, want 2 (one batch plus one fallback)", got)
+	}
+	for _, seg := range segments {
+		if seg.Translated != pseudoLocalizeMasked(t, seg.Text) {
+			t.Errorf("segment %q translated to %q", seg.Text, seg.Translated)
+		}
+	}
+}
+
+func pseudoLocalizeMasked(t *testing.T, text string) string {
+	t.Helper()
+	out, err := NewFakeTranslator().Translate(context.Background(), text, "en", "zh-CN", promptHints{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// truncatingTranslator fails batches of more than two segments the way the
+// openai backend reports a reply cut off at the output limit.
+type truncatingTranslator struct {
+	*FakeTranslator
+}
+
+func (t truncatingTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	if strings.Count(text, "<seg id=") > 2 {
+		return "", fmt.Errorf("openai %w (finish_reason=length)", errOutputTruncated)
+	}
+	return t.FakeTranslator.TranslateRaw(ctx, text, srcLang, tgtLang, hints)
+}
+
+func TestSegmentBatchSplitsTruncatedReplies(t *testing.T) {
+	segments := []*Segment{{Text: "One."}, {Text: "Two."}, {Text: "Three."}, {Text: "Four."}}
+	translator := truncatingTranslator{NewFakeTranslator()}
+	if err := translateSegments(context.Background(), translator, segments, "en", "zh-CN", segmentOptions{BatchSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if got := translator.Calls(); got != 2 {
+		t.Fatalf("calls = %d, want 2 (one per half)", got)
+	}
+	for _, seg := range segments {
+		if seg.Translated != pseudoLocalizeMasked(t, seg.Text) {
+			t.Errorf("segment %q translated to %q", seg.Text, seg.Translated)
+		}
+	}
+}
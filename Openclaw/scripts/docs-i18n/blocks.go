@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// markdownBlocks is a Markdown body split at blank lines outside fenced code.
+// Joining Lead, then each block followed by its separator, reproduces the
+// body byte for byte.
+type markdownBlocks struct {
+	Lead   string
+	Blocks []string
+	Seps   []string
+}
+
+func splitMarkdownBlocks(body string) markdownBlocks {
+	var out markdownBlocks
+	lines := strings.SplitAfter(body, "\n")
+	var block, sep strings.Builder
+	fence := ""
+	flush := func() {
+		if block.Len() == 0 {
+			return
+		}
+		out.Blocks = append(out.Blocks, block.String())
+		block.Reset()
+	}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		blank := strings.TrimSpace(line) == ""
+		if fence == "" && blank {
+			if block.Len() > 0 {
+				flush()
+				sep.Reset()
+			}
+			sep.WriteString(line)
+			continue
+		}
+		if block.Len() == 0 {
+			if len(out.Blocks) == 0 {
+				out.Lead = sep.String()
+			} else {
+				out.Seps = append(out.Seps, sep.String())
+			}
+			sep.Reset()
+		}
+		block.WriteString(line)
+		fence = updateFence(fence, line)
+	}
+	flush()
+	if len(out.Blocks) == 0 {
+		out.Lead = sep.String()
+		return out
+	}
+	out.Seps = append(out.Seps, sep.String())
+	return out
+}
+
+func (b markdownBlocks) join() string {
+	var out strings.Builder
+	out.WriteString(b.Lead)
+	for i, block := range b.Blocks {
+		out.WriteString(block)
+		if i < len(b.Seps) {
+			out.WriteString(b.Seps[i])
+		}
+	}
+	return out.String()
+}
+
+func (b markdownBlocks) hashes() []string {
+	hashes := make([]string, len(b.Blocks))
+	for i, block := range b.Blocks {
+		hashes[i] = shortHash(hashText(block))
+	}
+	return hashes
+}
+
+// updateFence tracks whether line opens or closes a fenced code block.
+// fence is the opening marker ("```", "~~~~", ...) or "" outside a fence.
+func updateFence(fence, line string) string {
+	trimmed := strings.TrimSpace(line)
+	if fence == "" {
+		for _, marker := range []byte{'`', '~'} {
+			run := leadingRun(trimmed, marker)
+			if run >= 3 {
+				return trimmed[:run]
+			}
+		}
+		return ""
+	}
+	if leadingRun(trimmed, fence[0]) >= len(fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+		return ""
+	}
+	return fence
+}
+
+func leadingRun(text string, marker byte) int {
+	count := 0
+	for count < len(text) && text[count] == marker {
+		count++
+	}
+	return count
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 16 {
+		return hash[:16]
+	}
+	return hash
+}
+
+// BlockRecord remembers the per-block hashes of the source a translation was
+// made from, so doc mode can retranslate only the blocks that changed, and of
+// the machine output, so human edits to it can be captured as overrides.
+type BlockRecord struct {
+	SourcePath string   `json:"source_path"`
+	SourceHash string   `json:"source_hash"`
+	FrontHash  string   `json:"front_hash"`
+	Blocks     []string `json:"blocks"`
+	Output     []string `json:"output,omitempty"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// BlockIndex is the per-language sidecar of BlockRecords
+// (docs/.i18n/<lang>.blocks.jsonl). It is safe for concurrent use.
+type BlockIndex struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]BlockRecord
+}
+
+func LoadBlockIndex(path string) (*BlockIndex, error) {
+	index := &BlockIndex{path: path, records: map[string]BlockRecord{}}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return index, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+			var record BlockRecord
+			if err := json.Unmarshal([]byte(trimmed), &record); err != nil {
+				return nil, fmt.Errorf("block index decode failed: %w", err)
+			}
+			if record.SourcePath != "" {
+				index.records[record.SourcePath] = record
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+func (index *BlockIndex) Get(relPath string) (BlockRecord, bool) {
+	if index == nil {
+		return BlockRecord{}, false
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	record, ok := index.records[relPath]
+	return record, ok
+}
+
+func (index *BlockIndex) Put(record BlockRecord) {
+	if index == nil || record.SourcePath == "" {
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.records[record.SourcePath] = record
+}
+
+func (index *BlockIndex) Delete(relPath string) {
+	if index == nil {
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	delete(index.records, relPath)
+}
+
+// Move re-files the record of a renamed source page under its new path.
+func (index *BlockIndex) Move(oldRel, newRel string) {
+	if index == nil {
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	record, ok := index.records[oldRel]
+	if !ok {
+		return
+	}
+	delete(index.records, oldRel)
+	record.SourcePath = newRel
+	index.records[newRel] = record
+}
+
+func (index *BlockIndex) Save() error {
+	if index == nil || index.path == "" {
+		return nil
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(index.path), 0o755); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(index.records))
+	for key := range index.records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tmpPath := index.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, key := range keys {
+		payload, err := json.Marshal(index.records[key])
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if _, err := writer.Write(append(payload, '\n')); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, index.path)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestSplitMarkdownBlocksRoundTrip(t *testing.T) {
+	body := "\n# Title\n\nFirst paragraph\nstill first.\n\n\n```bash\necho one\n\necho two\n```\n\n- item\n- item\n"
+	blocks := splitMarkdownBlocks(body)
+	if got := blocks.join(); got != body {
+		t.Fatalf("join() = %q, want %q", got, body)
+	}
+	want := []string{"# Title\n", "First paragraph\nstill first.\n", "```bash\necho one\n\necho two\n```\n", "- item\n- item\n"}
+	if len(blocks.Blocks) != len(want) {
+		t.Fatalf("got %d blocks %q, want %d", len(blocks.Blocks), blocks.Blocks, len(want))
+	}
+	for i := range want {
+		if blocks.Blocks[i] != want[i] {
+			t.Errorf("block %d = %q, want %q", i, blocks.Blocks[i], want[i])
+		}
+	}
+}
+
+func TestAlignBlocks(t *testing.T) {
+	got := alignBlocks([]string{"a", "b", "c", "d"}, []string{"a", "x", "c", "d", "e"})
+	want := []int{0, -1, 2, 3, -1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("alignBlocks = %v, want %v", got, want)
+		}
+	}
+	runs := changedRuns(got)
+	if len(runs) != 2 || runs[0] != (blockRun{Start: 1, Stop: 2}) || runs[1] != (blockRun{Start: 4, Stop: 5}) {
+		t.Fatalf("changedRuns = %v", runs)
+	}
+}
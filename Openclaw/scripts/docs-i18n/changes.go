@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// docChange is a source page added ('A'), modified ('M'), renamed ('R') or
+// deleted ('D') since a git ref. Paths are relative to the docs root.
+type docChange struct {
+	Status  byte
+	Path    string
+	OldPath string
+}
+
+// gitDocChanges lists the source pages that differ between ref and the
+// working tree, untracked pages included. Renames are detected by git.
+func gitDocChanges(docsRoot, ref string, dirs langDirs) ([]docChange, error) {
+	diff, err := runGit(docsRoot, "diff", "--name-status", "-M", "-z", "--relative", ref, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(docsRoot, "ls-files", "--others", "--exclude-standard", "-z", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	changes := parseNameStatus(diff)
+	for _, path := range strings.Split(strings.TrimRight(string(untracked), "\x00"), "\x00") {
+		if path != "" {
+			changes = append(changes, docChange{Status: 'A', Path: path})
+		}
+	}
+	return sourceDocChanges(changes, dirs), nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseNameStatus parses `git diff --name-status -z`. Copies count as added
+// pages and type changes as modified ones.
+func parseNameStatus(out []byte) []docChange {
+	fields := strings.Split(strings.TrimRight(string(out), "\x00"), "\x00")
+	var changes []docChange
+	for i := 0; i+1 < len(fields); {
+		status := fields[i]
+		if status == "" {
+			i++
+			continue
+		}
+		switch status[0] {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return changes
+			}
+			change := docChange{Status: 'R', Path: fields[i+2], OldPath: fields[i+1]}
+			if status[0] == 'C' {
+				change = docChange{Status: 'A', Path: fields[i+2]}
+			}
+			changes = append(changes, change)
+			i += 3
+		case 'A', 'D':
+			changes = append(changes, docChange{Status: status[0], Path: fields[i+1]})
+			i += 2
+		default:
+			changes = append(changes, docChange{Status: 'M', Path: fields[i+1]})
+			i += 2
+		}
+	}
+	return changes
+}
+
+// sourceDocChanges keeps the changes to source pages. A page renamed into or
+// out of the source tree counts as added or deleted.
+func sourceDocChanges(changes []docChange, dirs langDirs) []docChange {
+	var kept []docChange
+	for _, change := range changes {
+		change.Path = filepath.ToSlash(change.Path)
+		change.OldPath = filepath.ToSlash(change.OldPath)
+		if change.Status == 'R' {
+			switch oldSource, newSource := isSourceDoc(change.OldPath, dirs), isSourceDoc(change.Path, dirs); {
+			case oldSource && newSource:
+			case newSource:
+				change = docChange{Status: 'A', Path: change.Path}
+			case oldSource:
+				change = docChange{Status: 'D', Path: change.OldPath}
+			default:
+				continue
+			}
+		} else if !isSourceDoc(change.Path, dirs) {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept
+}
+
+// isSourceDoc reports whether rel (relative to the docs root, slash
+// separated) is a source page, by the same rules as listDocs.
+func isSourceDoc(rel string, dirs langDirs) bool {
+	if ext := filepath.Ext(rel); ext != ".md" && ext != ".mdx" {
+		return false
+	}
+	parents := strings.Split(rel, "/")
+	parents = parents[:len(parents)-1]
+	for i, dir := range parents {
+		if strings.HasPrefix(dir, ".") || i == 0 && dirs[dir] {
+			return false
+		}
+	}
+	return true
+}
+
+// movedPath rewrites path, or a "<path>:<suffix>" key derived from it, from
+// oldRel to newRel.
+func movedPath(path, oldRel, newRel string) (string, bool) {
+	if path == oldRel {
+		return newRel, true
+	}
+	if rest, ok := strings.CutPrefix(path, oldRel+":"); ok {
+		return newRel + ":" + rest, true
+	}
+	return "", false
+}
+
+// applyDocChanges carries renamed pages over before a -since run: the
+// translation moves with its source, and so do its translation memory
+// entries, block record and overrides. Translations of deleted pages are
+// removed with their sidecar records when deleteOrphans is set, and only
+// logged otherwise. The sidecars touched are saved.
+func applyDocChanges(changes []docChange, docsRoot, lang string, tm *TranslationMemory, blocks *BlockIndex, overrides *OverrideStore, deleteOrphans bool) error {
+	blocksChanged := false
+	movedEntries := 0
+	movedOverrides := 0
+	for _, change := range changes {
+		switch change.Status {
+		case 'R':
+			moved, err := moveTranslation(docsRoot, lang, change.OldPath, change.Path)
+			if err != nil {
+				return err
+			}
+			entries := tm.MoveSource(change.OldPath, change.Path)
+			movedEntries += entries
+			movedOverrides += overrides.MoveSource(change.OldPath, change.Path)
+			blocks.Move(change.OldPath, change.Path)
+			blocksChanged = true
+			log.Printf("docs-i18n: renamed %s -> %s (translation moved=%t, %d translation memory entries)", change.OldPath, change.Path, moved, entries)
+		case 'D':
+			outputPath := filepath.Join(docsRoot, lang, change.Path)
+			if _, err := os.Stat(outputPath); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			if !deleteOrphans {
+				log.Printf("docs-i18n: %s was deleted; its translation %s/%s is orphaned (-deleted=delete removes it)", change.Path, lang, change.Path)
+				continue
+			}
+			if err := os.Remove(outputPath); err != nil {
+				return err
+			}
+			movedOverrides += overrides.MoveSource(change.Path, "")
+			blocks.Delete(change.Path)
+			blocksChanged = true
+			log.Printf("docs-i18n: %s was deleted; removed its translation", change.Path)
+		}
+	}
+	if movedEntries > 0 {
+		if err := tm.Save(); err != nil {
+			return err
+		}
+	}
+	if blocksChanged {
+		if err := blocks.Save(); err != nil {
+			return err
+		}
+	}
+	if movedOverrides > 0 {
+		return overrides.Save()
+	}
+	return nil
+}
+
+// moveTranslation moves the translation of a renamed page, updating its
+// x-i18n source_path. Nothing is moved when there is no translation or one
+// already exists at the new path.
+func moveTranslation(docsRoot, lang, oldRel, newRel string) (bool, error) {
+	oldPath := filepath.Join(docsRoot, lang, oldRel)
+	newPath := filepath.Join(docsRoot, lang, newRel)
+	content, err := os.ReadFile(oldPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return false, nil
+	}
+	frontMatter, body := splitFrontMatter(string(content))
+	frontData := map[string]any{}
+	if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err == nil {
+		if meta, ok := frontData["x-i18n"].(map[string]any); ok {
+			meta["source_path"] = newRel
+			encoded, err := yaml.Marshal(frontData)
+			if err != nil {
+				return false, err
+			}
+			content = []byte(fmt.Sprintf("---\n%s---\n\n%s", encoded, body))
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(newPath, content, 0o644); err != nil {
+		return false, err
+	}
+	return true, os.Remove(oldPath)
+}
+
+// changedFiles is the queue for a -since run: every page added, modified or
+// renamed, as paths under the docs root.
+func changedFiles(docsRoot string, changes []docChange) []string {
+	var files []string
+	for _, change := range changes {
+		if change.Status != 'D' {
+			files = append(files, filepath.Join(docsRoot, filepath.FromSlash(change.Path)))
+		}
+	}
+	return files
+}
+
+// allSourceFiles is the queue for an -all run.
+func allSourceFiles(docsRoot string, dirs langDirs) ([]string, error) {
+	docs, err := listDocs(docsRoot, dirs)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(docs))
+	for _, rel := range docs {
+		files = append(files, filepath.Join(docsRoot, rel))
+	}
+	return files, nil
+}
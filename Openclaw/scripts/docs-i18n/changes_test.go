@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceDocChanges(t *testing.T) {
+	out := strings.Join([]string{
+		"M", "index.md",
+		"R100", "gateway/configuration.md", "gateway/config.md",
+		"A", "zh-CN/index.md",
+		"D", "old.mdx",
+		"M", ".i18n/zh-CN.tm.jsonl",
+		"R087", "drafts.txt", "channels/slack.md",
+		"R100", "channels/irc.md", "zh-CN/channels/irc.md",
+		"C075", "a.md", "b.md",
+		"T", "img/logo.png",
+		"M", "web-ui/panel.md",
+	}, "\x00") + "\x00"
+	got := sourceDocChanges(parseNameStatus([]byte(out)), langDirs{"zh-CN": true})
+	want := []docChange{
+		{Status: 'M', Path: "index.md"},
+		{Status: 'R', Path: "gateway/config.md", OldPath: "gateway/configuration.md"},
+		{Status: 'D', Path: "old.mdx"},
+		{Status: 'A', Path: "channels/slack.md"},
+		{Status: 'D', Path: "channels/irc.md"},
+		{Status: 'A', Path: "b.md"},
+		{Status: 'M', Path: "web-ui/panel.md"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestApplyDocRename(t *testing.T) {
+	docsRoot := t.TempDir()
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := LoadBlockIndex(filepath.Join(docsRoot, ".i18n", "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace := cacheNamespace("fake", "pseudo", "")
+	oldRel, newRel := "gateway/configuration.md", "gateway/config.md"
+	for _, id := range []string{segmentID(oldRel, hashText("Hello")), oldRel + ":frontmatter:title"} {
+		tm.Put(TMEntry{
+			CacheKey:   cacheKey(namespace, "en", "zh-CN", id, hashText("Hello")),
+			SegmentID:  id,
+			SourcePath: oldRel,
+			TextHash:   hashText("Hello"),
+			Text:       "Hello",
+			Translated: "你好",
+			Provider:   "fake",
+			Model:      "pseudo",
+			SrcLang:    "en",
+			TgtLang:    "zh-CN",
+		})
+	}
+	blocks.Put(BlockRecord{SourcePath: oldRel, SourceHash: "h"})
+	translation := "---\ntitle: 配置\nx-i18n:\n    source_path: gateway/configuration.md\n---\n\n# 配置\n"
+	oldPath := filepath.Join(docsRoot, "zh-CN", oldRel)
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldPath, []byte(translation), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []docChange{{Status: 'R', Path: newRel, OldPath: oldRel}}
+	if err := applyDocChanges(changes, docsRoot, "zh-CN", tm, blocks, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	key := cacheKey(namespace, "en", "zh-CN", segmentID(newRel, hashText("Hello")), hashText("Hello"))
+	if entry, ok := tm.Get(key); !ok || entry.SourcePath != newRel {
+		t.Errorf("moved entry = %+v, %v", entry, ok)
+	}
+	if entry, ok := tm.Get(cacheKey(namespace, "en", "zh-CN", newRel+":frontmatter:title", hashText("Hello"))); !ok || entry.SegmentID != newRel+":frontmatter:title" {
+		t.Errorf("moved frontmatter entry = %+v, %v", entry, ok)
+	}
+	if _, ok := blocks.Get(newRel); !ok {
+		t.Error("block record was not moved")
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("old translation still exists: %v", err)
+	}
+	moved, err := os.ReadFile(filepath.Join(docsRoot, "zh-CN", newRel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\ntitle: 配置\nx-i18n:\n    source_path: gateway/config.md\n---\n\n# 配置\n"; string(moved) != want {
+		t.Errorf("moved translation:\n%s\nwant:\n%s", moved, want)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	statusUpToDate = "up-to-date"
+	statusStale    = "stale"
+	statusMissing  = "missing"
+	statusOrphaned = "orphaned"
+)
+
+// langDirs holds the translation directories directly under a docs root.
+// They are the target languages, not guessed from names, so a source folder
+// such as web-ui is never mistaken for one.
+type langDirs map[string]bool
+
+// languageDirs returns the translation directories of docsRoot: langs plus
+// every language with a translation memory in docs/.i18n.
+func languageDirs(docsRoot string, langs []string) (langDirs, error) {
+	known, err := discoverLanguages(docsRoot)
+	if err != nil {
+		return nil, err
+	}
+	dirs := langDirs{}
+	for _, lang := range append(known, langs...) {
+		dirs[lang] = true
+	}
+	return dirs, nil
+}
+
+type checkPage struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+type checkLanguage struct {
+	Lang   string         `json:"lang"`
+	Counts map[string]int `json:"counts"`
+	Pages  []checkPage    `json:"pages"`
+}
+
+type checkReport struct {
+	Languages []checkLanguage `json:"languages"`
+}
+
+// runCheck implements `docs-i18n check`. It reports whether any page has a
+// status listed in -fail-on.
+func runCheck(args []string, stdout io.Writer) (bool, error) {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	var (
+		docsRoot = flags.String("docs", "docs", "docs root")
+		langs    = flags.String("lang", "", "comma-separated target languages (default: every language with a translation memory)")
+		format   = flags.String("format", "table", "report format (table|json)")
+		failOn   = flags.String("fail-on", "stale,missing,orphaned", "comma-separated statuses that fail the check")
+	)
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	resolvedDocsRoot, err := filepath.Abs(*docsRoot)
+	if err != nil {
+		return false, err
+	}
+
+	failStatuses := splitList(*failOn)
+	for _, status := range failStatuses {
+		switch status {
+		case statusUpToDate, statusStale, statusMissing, statusOrphaned:
+		default:
+			return false, fmt.Errorf("unknown -fail-on status %q (want %s, %s, %s or %s)", status, statusUpToDate, statusStale, statusMissing, statusOrphaned)
+		}
+	}
+	targetLangs := splitList(*langs)
+	if len(targetLangs) == 0 {
+		targetLangs, err = discoverLanguages(resolvedDocsRoot)
+		if err != nil {
+			return false, err
+		}
+		if len(targetLangs) == 0 {
+			// Nothing to check usually means a wrong -docs; passing would
+			// hide it.
+			return false, fmt.Errorf("no translation memory in %s; pass -lang", filepath.Join(resolvedDocsRoot, ".i18n"))
+		}
+	}
+	dirs, err := languageDirs(resolvedDocsRoot, targetLangs)
+	if err != nil {
+		return false, err
+	}
+	report, err := checkTranslations(resolvedDocsRoot, targetLangs, dirs)
+	if err != nil {
+		return false, err
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return false, err
+		}
+	case "table":
+		if err := writeCheckTable(stdout, report); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("unknown format: %s", *format)
+	}
+
+	for _, status := range failStatuses {
+		for _, lang := range report.Languages {
+			if lang.Counts[status] > 0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// checkTranslations classifies every source page for each target language by
+// comparing its hash with the source_hash recorded in the translation.
+func checkTranslations(docsRoot string, langs []string, dirs langDirs) (checkReport, error) {
+	sources, err := listDocs(docsRoot, dirs)
+	if err != nil {
+		return checkReport{}, err
+	}
+	sourceSet := make(map[string]bool, len(sources))
+	for _, rel := range sources {
+		sourceSet[rel] = true
+	}
+
+	var report checkReport
+	for _, lang := range langs {
+		result := checkLanguage{Lang: lang, Counts: map[string]int{}}
+		add := func(rel, status string) {
+			result.Pages = append(result.Pages, checkPage{Path: filepath.ToSlash(rel), Status: status})
+			result.Counts[status]++
+		}
+		for _, rel := range sources {
+			status, err := translationStatus(docsRoot, lang, rel)
+			if err != nil {
+				return checkReport{}, err
+			}
+			add(rel, status)
+		}
+		translated, err := listDocs(filepath.Join(docsRoot, lang), nil)
+		if err != nil {
+			return checkReport{}, err
+		}
+		for _, rel := range translated {
+			if !sourceSet[rel] {
+				add(rel, statusOrphaned)
+			}
+		}
+		sort.Slice(result.Pages, func(i, j int) bool {
+			return result.Pages[i].Path < result.Pages[j].Path
+		})
+		report.Languages = append(report.Languages, result)
+	}
+	return report, nil
+}
+
+func translationStatus(docsRoot, lang, rel string) (string, error) {
+	source, err := os.ReadFile(filepath.Join(docsRoot, rel))
+	if err != nil {
+		return "", err
+	}
+	translated, err := os.ReadFile(filepath.Join(docsRoot, lang, rel))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return statusMissing, nil
+		}
+		return "", err
+	}
+	frontMatter, _ := splitFrontMatter(string(translated))
+	frontData := map[string]any{}
+	if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err != nil {
+		return statusStale, nil
+	}
+	if strings.EqualFold(extractSourceHash(frontData), hashBytes(source)) {
+		return statusUpToDate, nil
+	}
+	return statusStale, nil
+}
+
+// listDocs returns the Markdown pages under root relative to it. The
+// translation directories in skip are left out, giving the source pages of a
+// docs root.
+func listDocs(root string, skip langDirs) ([]string, error) {
+	var docs []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if path == root {
+				return nil
+			}
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || (filepath.Dir(path) == root && skip[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".md" && ext != ".mdx" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(docs)
+	return docs, nil
+}
+
+// discoverLanguages lists the languages with a translation memory in
+// docs/.i18n.
+func discoverLanguages(docsRoot string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(docsRoot, ".i18n"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var langs []string
+	for _, entry := range entries {
+		if lang, ok := strings.CutSuffix(entry.Name(), ".tm.jsonl"); ok && !entry.IsDir() && lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs, nil
+}
+
+func writeCheckTable(w io.Writer, report checkReport) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "LANG\tSTATUS\tPATH")
+	for _, lang := range report.Languages {
+		for _, page := range lang.Pages {
+			if page.Status == statusUpToDate {
+				continue
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\n", lang.Lang, page.Status, page.Path)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	for _, lang := range report.Languages {
+		fmt.Fprintf(w, "%s: %d up-to-date, %d stale, %d missing, %d orphaned\n", lang.Lang,
+			lang.Counts[statusUpToDate], lang.Counts[statusStale], lang.Counts[statusMissing], lang.Counts[statusOrphaned])
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
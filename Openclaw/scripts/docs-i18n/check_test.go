@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckClassifiesTranslations(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	for _, file := range fixtureFiles(t, docsRoot) {
+		if _, err := processFilePseudo(context.Background(), NewFakeTranslator(), nil, docsRoot, file, "en", "zh-CN", false, defaultComponentAttrs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(docsRoot, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("index.md", "# Changed\n")
+	writeFile("start/new.md", "# New page\n")
+	writeFile("web-ui/panel.md", "# Panel\n")
+	writeFile(".i18n/zh-CN.tm.jsonl", "")
+	writeFile("zh-CN/removed.md", "# 已删除\n")
+
+	var out bytes.Buffer
+	clean, err := runCheck([]string{"-docs", docsRoot, "-format", "json"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Fatal("check passed with stale, missing and orphaned pages")
+	}
+	var report checkReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Languages) != 1 || report.Languages[0].Lang != "zh-CN" {
+		t.Fatalf("languages = %+v, want only zh-CN", report.Languages)
+	}
+	want := map[string]string{
+		"gateway/configuration.md": statusUpToDate,
+		"index.md":                 statusStale,
+		"removed.md":               statusOrphaned,
+		"start/new.md":             statusMissing,
+		"web-ui/panel.md":          statusMissing,
+	}
+	pages := report.Languages[0].Pages
+	if len(pages) != len(want) {
+		t.Fatalf("pages = %+v, want %d entries", pages, len(want))
+	}
+	for _, page := range pages {
+		if want[page.Path] != page.Status {
+			t.Errorf("%s: status %q, want %q", page.Path, page.Status, want[page.Path])
+		}
+	}
+
+	clean, err = runCheck([]string{"-docs", docsRoot, "-fail-on", "stale"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Fatal("-fail-on stale ignored the stale page")
+	}
+
+	if _, err := runCheck([]string{"-docs", docsRoot, "-fail-on", "stal"}, &bytes.Buffer{}); err == nil {
+		t.Error("a misspelled -fail-on status was accepted")
+	}
+	if _, err := runCheck([]string{"-docs", t.TempDir()}, &bytes.Buffer{}); err == nil {
+		t.Error("a docs root without translation memories passed")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// codeCommentMarkers maps fenced code info strings to their line comment
+// marker.
+var codeCommentMarkers = map[string]string{
+	"bash":       "#",
+	"sh":         "#",
+	"shell":      "#",
+	"zsh":        "#",
+	"powershell": "#",
+	"python":     "#",
+	"py":         "#",
+	"toml":       "#",
+	"yaml":       "#",
+	"yml":        "#",
+	"c":          "//",
+	"go":         "//",
+	"java":       "//",
+	"javascript": "//",
+	"js":         "//",
+	"json5":      "//",
+	"jsonc":      "//",
+	"kotlin":     "//",
+	"rust":       "//",
+	"swift":      "//",
+	"ts":         "//",
+	"typescript": "//",
+}
+
+// codeCommentPolicy maps the info strings of the fenced code blocks whose
+// comments are translated to their comment marker. An empty policy
+// translates none.
+type codeCommentPolicy map[string]string
+
+// parseCodeCommentPolicy builds the policy for a list of info strings.
+func parseCodeCommentPolicy(langs []string) (codeCommentPolicy, error) {
+	policy := codeCommentPolicy{}
+	for _, lang := range langs {
+		lang = strings.ToLower(lang)
+		marker, ok := codeCommentMarkers[lang]
+		if !ok {
+			return nil, fmt.Errorf("no comment syntax known for code blocks tagged %q", lang)
+		}
+		policy[lang] = marker
+	}
+	return policy, nil
+}
+
+// allCodeComments covers every info string with a known comment syntax.
+func allCodeComments() codeCommentPolicy {
+	return codeCommentPolicy(codeCommentMarkers)
+}
+
+// extractCodeComments returns a segment for the text of every full-line
+// comment in the fenced code blocks the policy covers. A segment spans only
+// the comment text, after the marker and before the line break, so the code
+// around it is never touched. Shebangs and comments without letters are left
+// alone.
+func extractCodeComments(body, relPath string, policy codeCommentPolicy) []Segment {
+	if len(policy) == 0 {
+		return nil
+	}
+	source := []byte(body)
+	var segments []Segment
+	_ = ast.Walk(parseHeadings(source), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		marker, ok := policy[strings.ToLower(string(block.Language(source)))]
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		lines := block.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			start, stop, ok := commentText(source, line.Start, line.Stop, marker)
+			if !ok {
+				continue
+			}
+			textValue := string(source[start:stop])
+			textHash := hashText(textValue)
+			segments = append(segments, Segment{
+				Start:     start,
+				Stop:      stop,
+				Text:      textValue,
+				TextHash:  textHash,
+				SegmentID: segmentID(relPath, textHash),
+				Comment:   true,
+			})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return segments
+}
+
+// commentText finds the text of a line comment in source[start:stop]: after
+// the marker and any repeats of it, without surrounding whitespace.
+func commentText(source []byte, start, stop int, marker string) (int, int, bool) {
+	for start < stop && (source[start] == ' ' || source[start] == '\t') {
+		start++
+	}
+	if !bytes.HasPrefix(source[start:stop], []byte(marker)) || bytes.HasPrefix(source[start:stop], []byte("#!")) {
+		return 0, 0, false
+	}
+	for start < stop && (source[start] == marker[0] || source[start] == ' ' || source[start] == '\t') {
+		start++
+	}
+	for stop > start && unicode.IsSpace(rune(source[stop-1])) {
+		stop--
+	}
+	if !strings.ContainsFunc(string(source[start:stop]), unicode.IsLetter) {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// withCodeComments adds the code comment segments to the prose segments of a
+// page, in document order.
+func withCodeComments(segments []Segment, body, relPath string, policy codeCommentPolicy) []Segment {
+	comments := extractCodeComments(body, relPath, policy)
+	if len(comments) == 0 {
+		return segments
+	}
+	segments = append(segments, comments...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const codeCommentSource = "# Setup\n\n" +
+	"```bash\n#!/usr/bin/env bash\n# Install the CLI first.\nnpm i -g openclaw # not a full-line comment\n  ## Then start the Gateway.\n#\n```\n\n" +
+	"```json5 openclaw.json\n{\n  // Bind to loopback only.\n  gateway: { bind: \"loopback\" },\n}\n```\n\n" +
+	"```python\n# Left in English.\n```\n"
+
+func TestExtractCodeComments(t *testing.T) {
+	policy, err := parseCodeCommentPolicy([]string{"bash", "JSON5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, seg := range extractCodeComments(codeCommentSource, "setup.md", policy) {
+		if codeCommentSource[seg.Start:seg.Stop] != seg.Text || !seg.Comment {
+			t.Errorf("segment %+v does not cover its text", seg)
+		}
+		got = append(got, seg.Text)
+	}
+	want := []string{"Install the CLI first.", "Then start the Gateway.", "Bind to loopback only."}
+	if !slices.Equal(got, want) {
+		t.Fatalf("comments = %q, want %q", got, want)
+	}
+
+	if _, err := parseCodeCommentPolicy([]string{"brainfuck"}); err == nil {
+		t.Error("unknown info string accepted")
+	}
+}
+
+func TestSegmentModeTranslatesOnlyCommentText(t *testing.T) {
+	docsRoot := t.TempDir()
+	file := filepath.Join(docsRoot, "setup.md")
+	if err := os.WriteFile(file, []byte(codeCommentSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := parseCodeCommentPolicy([]string{"bash", "json5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := segmentOptions{BatchSize: defaultBatchSize, CodeComments: policy}
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, nil, nil, docsRoot, file, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(docsRoot, "zh-CN", "setup.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body := splitFrontMatter(string(data))
+
+	sourceLines := strings.Split(codeCommentSource, "\n")
+	translatedLines := strings.Split(body, "\n")
+	if len(translatedLines) != len(sourceLines) {
+		t.Fatalf("line count changed:\n%s", body)
+	}
+	translated := map[string]bool{"# Setup": true, "# Install the CLI first.": true, "  ## Then start the Gateway.": true, "  // Bind to loopback only.": true}
+	for i, line := range sourceLines {
+		switch {
+		case !translated[line]:
+			if translatedLines[i] != line {
+				t.Errorf("line %d changed: %q -> %q", i+1, line, translatedLines[i])
+			}
+		case line == "# Setup":
+		default:
+			marker := line[:strings.IndexFunc(line, func(r rune) bool { return r >= 'A' && r <= 'Z' })]
+			if !strings.HasPrefix(translatedLines[i], marker+"⟦") {
+				t.Errorf("line %d not translated in place: %q", i+1, translatedLines[i])
+			}
+		}
+	}
+	if _, ok := tm.FindText(cacheNamespace("fake", "pseudo", ""), "en", "zh-CN", hashText("Bind to loopback only.")); !ok {
+		t.Error("comment translation not stored in the translation memory")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// componentAttrs lists, per Mintlify component or HTML tag, the attributes
+// whose values are prose to translate. Names are case-sensitive, as in MDX.
+// Every other attribute is copied verbatim.
+type componentAttrs map[string][]string
+
+var defaultComponentAttrs = componentAttrs{
+	"Accordion":  {"title", "description"},
+	"Card":       {"title"},
+	"Expandable": {"title"},
+	"Frame":      {"caption"},
+	"Step":       {"title"},
+	"Tab":        {"title"},
+	"Tooltip":    {"headline", "tip", "cta"},
+	"Update":     {"label", "description"},
+}
+
+// LoadComponentAttrs returns the built-in allowlist overlaid with path, a
+// JSON object of component name to attribute list. A component mapped to []
+// has none of its attributes translated. A missing file keeps the built-ins.
+func LoadComponentAttrs(path string) (componentAttrs, error) {
+	components := maps.Clone(defaultComponentAttrs)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return components, nil
+		}
+		return nil, err
+	}
+	var overlay componentAttrs
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return nil, fmt.Errorf("component config parse failed: %w", err)
+	}
+	maps.Copy(components, overlay)
+	return components, nil
+}
+
+// tagAttr is the value of one quoted attribute in a start tag: tag[Start:Stop]
+// between Quote characters.
+type tagAttr struct {
+	Name  string
+	Start int
+	Stop  int
+	Quote byte
+}
+
+// scanStartTag splits a start tag into its name and quoted attribute values.
+// It understands JSX {expression} values (skipped, braces balanced) so a ">"
+// inside one doesn't end the tag. ok is false for anything but a start tag.
+func scanStartTag(tag string) (name string, attrs []tagAttr, ok bool) {
+	if len(tag) < 2 || tag[0] != '<' || !isTagNameByte(tag[1]) {
+		return "", nil, false
+	}
+	i := 1
+	for i < len(tag) && isTagNameByte(tag[i]) {
+		i++
+	}
+	name = tag[1:i]
+	for i < len(tag) {
+		for i < len(tag) && isSpaceByte(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] == '>' || tag[i] == '/' {
+			break
+		}
+		if tag[i] == '{' {
+			// JSX spread: {...props}
+			i = skipJSXExpression(tag, i)
+			continue
+		}
+		attrStart := i
+		for i < len(tag) && !isSpaceByte(tag[i]) && !strings.ContainsRune("=>/", rune(tag[i])) {
+			i++
+		}
+		attr := tag[attrStart:i]
+		for i < len(tag) && isSpaceByte(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] != '=' {
+			continue
+		}
+		i++
+		for i < len(tag) && isSpaceByte(tag[i]) {
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		switch quote := tag[i]; quote {
+		case '"', '\'':
+			end := strings.IndexByte(tag[i+1:], quote)
+			if end < 0 {
+				return name, attrs, true
+			}
+			attrs = append(attrs, tagAttr{Name: attr, Start: i + 1, Stop: i + 1 + end, Quote: quote})
+			i += end + 2
+		case '{':
+			i = skipJSXExpression(tag, i)
+		default:
+			for i < len(tag) && !isSpaceByte(tag[i]) && tag[i] != '>' {
+				i++
+			}
+		}
+	}
+	return name, attrs, true
+}
+
+// skipJSXExpression returns the index just past the {…} expression starting
+// at text[start], honouring nested braces and string literals. An unclosed
+// expression runs to the end of text.
+func skipJSXExpression(text string, start int) int {
+	depth := 0
+	for i := start; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return len(text)
+			}
+			i += end + 1
+		}
+	}
+	return len(text)
+}
+
+func isTagNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':'
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// attrSegmentID is the translation memory segment of a component attribute
+// on relPath. It holds no position, so moving a component keeps its
+// translation.
+func attrSegmentID(relPath, component, attr string) string {
+	return relPath + ":attr:" + component + "." + attr
+}
+
+// translateTag translates the allowlisted attribute values of a start tag
+// through the translation memory. Everything else in the tag, including JSX
+// expressions, is kept byte for byte; quotes in a translation are escaped so
+// the value stays closed.
+func (c componentAttrs) translateTag(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, tag, srcLang, tgtLang string) (string, error) {
+	name, attrs, ok := scanStartTag(tag)
+	if !ok || len(c[name]) == 0 {
+		return tag, nil
+	}
+	var out strings.Builder
+	pos := 0
+	for _, attr := range attrs {
+		value := tag[attr.Start:attr.Stop]
+		if !slices.Contains(c[name], attr.Name) || strings.TrimSpace(value) == "" {
+			continue
+		}
+		translated, err := translateSnippet(ctx, translator, tm, attrSegmentID(relPath, name, attr.Name), value, srcLang, tgtLang)
+		if err != nil {
+			return "", fmt.Errorf("<%s %s>: %w", name, attr.Name, err)
+		}
+		if attr.Quote == '"' {
+			translated = strings.ReplaceAll(translated, `"`, "&quot;")
+		} else {
+			translated = strings.ReplaceAll(translated, "'", "&#39;")
+		}
+		out.WriteString(tag[pos:attr.Start])
+		out.WriteString(translated)
+		pos = attr.Stop
+	}
+	out.WriteString(tag[pos:])
+	return out.String(), nil
+}
+
+// maskJSXExpressions replaces every {expression} in an HTML block with a
+// placeholder, so neither the HTML tokenizer nor the model sees JSX.
+func maskJSXExpressions(text string, nextPlaceholder func() string, placeholders *[]string, mapping map[string]string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	var out strings.Builder
+	pos := 0
+	for {
+		open := strings.IndexByte(text[pos:], '{')
+		if open < 0 {
+			break
+		}
+		open += pos
+		end := skipJSXExpression(text, open)
+		out.WriteString(text[pos:open])
+		placeholder := nextPlaceholder()
+		mapping[placeholder] = text[open:end]
+		*placeholders = append(*placeholders, placeholder)
+		out.WriteString(placeholder)
+		pos = end
+	}
+	out.WriteString(text[pos:])
+	return out.String()
+}
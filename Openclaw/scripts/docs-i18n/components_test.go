@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const componentsSource = `Run the <Tooltip headline="Gateway host" tip="The machine running the gateway.">gateway</Tooltip> first.
+
+<CardGroup cols={2}>
+  <Card title="Pairing" icon="link" href="/channels/pairing">
+    Default DM policy is "pairing".
+  </Card>
+  <Tab title='Setup' data={{ a: "x > y" }} />
+</CardGroup>
+`
+
+func TestTranslateComponentAttrs(t *testing.T) {
+	got, err := translateHTMLBlocks(context.Background(), NewFakeTranslator(), nil, "components.md", componentsSource, "en", "zh-CN", defaultComponentAttrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<Tooltip headline="` + pseudoLocalize("Gateway host") + `" tip="` + pseudoLocalize("The machine running the gateway.") + `">`,
+		`<CardGroup cols={2}>`,
+		`<Card title="` + pseudoLocalize("Pairing") + `" icon="link" href="/channels/pairing">`,
+		pseudoLocalize(`Default DM policy is "pairing".`),
+		`<Tab title='` + pseudoLocalize("Setup") + `' data={{ a: "x > y" }} />`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output is missing %s:\n%s", want, got)
+		}
+	}
+
+	unchanged, err := translateHTMLBlocks(context.Background(), NewFakeTranslator(), nil, "components.md", componentsSource, "en", "zh-CN", componentAttrs{"Card": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(unchanged, `<Card title="Pairing"`) || !strings.Contains(unchanged, `headline="Gateway host"`) {
+		t.Errorf("attributes outside the allowlist were translated:\n%s", unchanged)
+	}
+}
+
+// tagTranslator answers every request with markup.
+type tagTranslator struct {
+	*FakeTranslator
+}
+
+func (t *tagTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return `默认 "配对" <b>策略</b>`, nil
+}
+
+func TestTranslateHTMLBlockVerifiesTags(t *testing.T) {
+	translator := &tagTranslator{FakeTranslator: NewFakeTranslator()}
+	_, err := translateHTMLBlock(context.Background(), translator, nil, "components.md", "<Note>\n  Default policy.\n</Note>", "en", "zh-CN", nil)
+	if err == nil || !strings.Contains(err.Error(), "tags changed") {
+		t.Fatalf("err = %v, want a tag mismatch", err)
+	}
+
+	tag, err := defaultComponentAttrs.translateTag(context.Background(), translator, nil, "components.md", `<Card title="Pairing">`, "en", "zh-CN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<Card title="默认 &quot;配对&quot; <b>策略</b>">`; tag != want {
+		t.Fatalf("translateTag = %s, want %s", tag, want)
+	}
+}
+
+func TestLoadComponentAttrs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "components.json")
+	if err := os.WriteFile(path, []byte(`{"Card": ["title", "description"], "Tab": [], "Badge": ["label"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	components, err := LoadComponentAttrs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(components["Card"], ","); got != "title,description" {
+		t.Errorf("Card = %s", got)
+	}
+	if len(components["Tab"]) != 0 || len(components["Badge"]) != 1 || len(components["Step"]) != 1 {
+		t.Errorf("components = %v", components)
+	}
+	if len(defaultComponentAttrs["Card"]) != 1 {
+		t.Error("loading a config changed the built-in allowlist")
+	}
+}
+
+// htmlEditingTranslator answers doc mode requests through the fake backend,
+// then rewrites attributes and JSX the way a careless model might.
+type htmlEditingTranslator struct {
+	*FakeTranslator
+}
+
+func (t htmlEditingTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	out, err := t.FakeTranslator.TranslateRaw(ctx, text, srcLang, tgtLang, hints)
+	if err != nil {
+		return "", err
+	}
+	return strings.NewReplacer(`icon="link"`, `icon="链接"`, `cols={2}`, `cols={3}`).Replace(out), nil
+}
+
+func TestDocModeTranslatesComponentsLikeSegmentMode(t *testing.T) {
+	docsRoot := t.TempDir()
+	file := filepath.Join(docsRoot, "components.md")
+	if err := os.WriteFile(file, []byte("---\ntitle: Components\n---\n\n"+componentsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tm, err := LoadTranslationMemory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := docOptions{Components: defaultComponentAttrs, TM: tm}
+	if _, err := processFileDoc(context.Background(), htmlEditingTranslator{NewFakeTranslator()}, nil, nil, docsRoot, file, "en", "zh-CN", true, opts); err != nil {
+		t.Fatal(err)
+	}
+	output, err := os.ReadFile(filepath.Join(docsRoot, "zh-CN", "components.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<CardGroup cols={2}>`,
+		`<Card title="` + pseudoLocalize("Pairing") + `" icon="link" href="/channels/pairing">`,
+		`<Tab title='` + pseudoLocalize("Setup") + `' data={{ a: "x > y" }} />`,
+	} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("output is missing %s:\n%s", want, output)
+		}
+	}
+	textHash := hashText("Pairing")
+	namespace := cacheNamespace("fake", "pseudo", "")
+	id := attrSegmentID("components.md", "Card", "title")
+	if _, ok := tm.Get(cacheKey(namespace, "en", "zh-CN", id, textHash)); !ok {
+		t.Errorf("attribute translation was not stored under %s", id)
+	}
+
+	// A second run finds the HTML in the translation memory.
+	translator := NewFakeTranslator()
+	if _, err := translateHTMLBlocks(context.Background(), translator, tm, "components.md", componentsSource, "en", "zh-CN", defaultComponentAttrs); err != nil {
+		t.Fatal(err)
+	}
+	if got := translator.Calls(); got != 0 {
+		t.Errorf("calls = %d, want 0", got)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const defaultChunkTokens = 6000
+
+var errStructureMismatch = errors.New("translated structure mismatch")
+
+// docChunk is a slice of a doc body translated in its own request. Text has
+// no trailing newlines; Trailing holds them so chunks join back exactly.
+type docChunk struct {
+	Text     string
+	Trailing string
+}
+
+// estimateTokens is a rough budget estimate (about four bytes per token for
+// English prose), good enough to keep requests under the output limit.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// planDocChunks splits a body at H2/H3 headings so each chunk stays under the
+// token budget. A section that is too large on its own is split further at
+// block boundaries. A budget of 0 disables chunking.
+func planDocChunks(body string, budget int) []docChunk {
+	if budget <= 0 || estimateTokens(body) <= budget {
+		return []docChunk{{Text: body}}
+	}
+	var pieces []string
+	for _, section := range splitAtHeadings(body) {
+		if estimateTokens(section) <= budget {
+			pieces = append(pieces, section)
+			continue
+		}
+		blocks := splitMarkdownBlocks(section)
+		if blocks.Lead != "" {
+			pieces = append(pieces, blocks.Lead)
+		}
+		for i, block := range blocks.Blocks {
+			pieces = append(pieces, block+blocks.Seps[i])
+		}
+	}
+
+	var chunks []docChunk
+	var current strings.Builder
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		text := current.String()
+		trimmed := strings.TrimRight(text, "\n")
+		chunks = append(chunks, docChunk{Text: trimmed, Trailing: text[len(trimmed):]})
+		current.Reset()
+	}
+	for _, piece := range pieces {
+		if current.Len() > 0 && estimateTokens(current.String())+estimateTokens(piece) > budget {
+			flush()
+		}
+		current.WriteString(piece)
+	}
+	flush()
+	return chunks
+}
+
+// splitAtHeadings cuts a body before every H2/H3 heading outside fenced code.
+func splitAtHeadings(body string) []string {
+	var sections []string
+	var current strings.Builder
+	fence := ""
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if fence == "" && isChunkHeading(line) && current.Len() > 0 {
+			sections = append(sections, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+		fence = updateFence(fence, line)
+	}
+	if current.Len() > 0 {
+		sections = append(sections, current.String())
+	}
+	return sections
+}
+
+func isChunkHeading(line string) bool {
+	return strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ")
+}
+
+// extractFencedBlocks returns every fenced code block, fences included.
+func extractFencedBlocks(text string) []string {
+	var blocks []string
+	var current strings.Builder
+	fence := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		next := updateFence(fence, line)
+		if fence != "" || next != "" {
+			current.WriteString(line)
+		}
+		if fence != "" && next == "" {
+			blocks = append(blocks, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+		fence = next
+	}
+	if current.Len() > 0 {
+		blocks = append(blocks, strings.TrimRight(current.String(), "\n"))
+	}
+	return blocks
+}
+
+// docStructureAttempts is how many times a tagged request is sent when the
+// model damages the document structure. Failed requests are retried by the
+// backend, not here.
+const docStructureAttempts = 2
+
+// translateTaggedDoc sends one tagged request and validates the reply, asking
+// once more when the model damaged the document structure.
+func translateTaggedDoc(ctx context.Context, translator Translator, input, sourceBody, srcLang, tgtLang string, hints promptHints) (string, string, error) {
+	for attempt := 1; ; attempt++ {
+		translated, err := translator.TranslateRaw(ctx, input, srcLang, tgtLang, hints)
+		if err != nil {
+			return "", "", err
+		}
+		front, body, err := parseTaggedDocument(stripContextBlock(translated))
+		if err != nil {
+			err = fmt.Errorf("tagged output invalid: %w", err)
+		} else {
+			err = validateDocStructure(sourceBody, body)
+		}
+		if err == nil {
+			return front, body, nil
+		}
+		emitFileEvent(ctx, runEvent{Event: "validation_failure", Attempt: attempt, Error: err.Error()})
+		if attempt == docStructureAttempts {
+			return "", "", err
+		}
+		emitFileEvent(ctx, runEvent{Event: "retry", Attempt: attempt + 1, Error: err.Error()})
+	}
+}
+
+// translateDocChunks translates the chunks of a body, the frontmatter riding
+// along with the first one. With more than one chunk and opts.ChunkParallel
+// above 1, chunks are spread over extra translators from opts.Spares.
+func translateDocChunks(ctx context.Context, translator Translator, opts docOptions, frontTemplate string, chunks []docChunk, srcLang, tgtLang string, hints promptHints) (string, string, error) {
+	if len(chunks) == 1 {
+		return translateTaggedDoc(ctx, translator, formatTaggedDocument(frontTemplate, chunks[0].Text), chunks[0].Text, srcLang, tgtLang, hints)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bodies := make([]string, len(chunks))
+	var front string
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	workers := min(max(opts.ChunkParallel, 1), len(chunks))
+	if opts.Spares == nil {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			chunkTranslator := translator
+			if worker > 0 {
+				extra, err := opts.Spares.get()
+				if err != nil {
+					fail(err)
+					for range jobs {
+					}
+					return
+				}
+				defer opts.Spares.put(extra)
+				chunkTranslator = extra
+			}
+			for index := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				chunk := chunks[index]
+				template := ""
+				if index == 0 {
+					template = frontTemplate
+				}
+				translatedFront, translatedBody, err := translateTaggedDoc(ctx, chunkTranslator, formatTaggedDocument(template, chunk.Text), chunk.Text, srcLang, tgtLang, hints)
+				if err != nil {
+					fail(fmt.Errorf("chunk %d/%d: %w", index+1, len(chunks), err))
+					continue
+				}
+				if index == 0 {
+					front = translatedFront
+				}
+				bodies[index] = strings.TrimRight(translatedBody, "\n") + chunk.Trailing
+			}
+		}(worker)
+	}
+	for index := range chunks {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return "", "", firstErr
+	}
+	return front, strings.Join(bodies, ""), nil
+}
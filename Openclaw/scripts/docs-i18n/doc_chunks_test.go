@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanDocChunksSplitsAtHeadings(t *testing.T) {
+	body := "Intro paragraph.\n\n## First\n\nSome text here.\n\n```bash\n## not a heading\n```\n\n### Second\n\nMore text.\n"
+	chunks := planDocChunks(body, 8)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		if strings.HasPrefix(chunk.Text, "## not a heading") {
+			t.Errorf("split inside a code fence: %q", chunk.Text)
+		}
+		joined.WriteString(chunk.Text + chunk.Trailing)
+	}
+	if joined.String() != body {
+		t.Fatalf("chunks join to %q, want %q", joined.String(), body)
+	}
+	if got := planDocChunks(body, 0); len(got) != 1 || got[0].Text != body {
+		t.Fatalf("budget 0 should keep one chunk, got %v", got)
+	}
+}
+
+// untaggedTranslator counts requests and never returns a tagged document.
+type untaggedTranslator struct {
+	*FakeTranslator
+	calls *int
+}
+
+func (t untaggedTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	*t.calls++
+	return "Sure, here is the translation.", nil
+}
+
+func TestTranslateTaggedDocRetriesStructureOnce(t *testing.T) {
+	calls := 0
+	translator := untaggedTranslator{NewFakeTranslator(), &calls}
+	_, _, err := translateTaggedDoc(context.Background(), translator, "input", "Body.\n", "en", "zh-CN", promptHints{})
+	if err == nil || !strings.Contains(err.Error(), "tagged output invalid") {
+		t.Fatalf("err = %v, want tagged output invalid", err)
+	}
+	if calls != docStructureAttempts {
+		t.Errorf("requests = %d, want %d", calls, docStructureAttempts)
+	}
+}
+
+func TestDocModeChunkedGolden(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	opts := docOptions{
+		ChunkTokens:   40,
+		ChunkParallel: 3,
+		Spares:        newSpareTranslators(func() (Translator, error) { return NewFakeTranslator(), nil }),
+	}
+	for _, file := range fixtureFiles(t, docsRoot) {
+		if _, err := processFileDoc(context.Background(), NewFakeTranslator(), nil, nil, docsRoot, file, "en", "zh-CN", false, opts); err != nil {
+			t.Fatalf("processFileDoc(%s): %v", file, err)
+		}
+	}
+	assertGoldenTree(t, filepath.Join(docsRoot, "zh-CN"), filepath.Join("testdata", "golden", "doc"))
+}
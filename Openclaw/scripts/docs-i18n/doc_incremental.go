@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	contextTagStart = "<context>"
+	contextTagEnd   = "</context>"
+)
+
+// blockRun is a half-open range [Start, Stop) of changed source blocks.
+type blockRun struct {
+	Start int
+	Stop  int
+}
+
+// translateDocIncremental retranslates only the source blocks whose hashes are
+// not in the block index, splicing the results into the existing translation.
+// Besides the body it returns the machine output hash of every block (see
+// BlockRecord.Output). It reports ok=false when the existing translation
+// cannot be reused (no record, blocks out of sync, too much changed) so the
+// caller falls back to a full translation. frontData is only modified when ok
+// is true.
+func translateDocIncremental(ctx context.Context, translator Translator, index *BlockIndex, relPath, outputPath, sourceFront string, frontData map[string]any, source markdownBlocks, srcLang, tgtLang string, opts docOptions) (string, []string, bool, error) {
+	record, ok := index.Get(relPath)
+	if !ok || len(record.Blocks) == 0 {
+		return "", nil, false, nil
+	}
+	existing, err := os.ReadFile(outputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, false, nil
+		}
+		return "", nil, false, err
+	}
+	existingFront, existingBody := splitFrontMatter(string(existing))
+	previous := splitMarkdownBlocks(existingBody)
+	if len(previous.Blocks) != len(record.Blocks) {
+		return "", nil, false, nil
+	}
+
+	matches := alignBlocks(record.Blocks, source.hashes())
+	runs := changedRuns(matches)
+	changed := 0
+	for _, run := range runs {
+		changed += run.Stop - run.Start
+	}
+	if changed*2 > len(source.Blocks) {
+		return "", nil, false, nil
+	}
+
+	front := cloneFrontData(frontData)
+	frontChanged := record.FrontHash != hashText(sourceFront)
+	if !frontChanged {
+		if err := copyTranslatedFront(front, existingFront); err != nil {
+			return "", nil, false, nil
+		}
+	}
+	if len(runs) == 0 && !frontChanged {
+		// Only whitespace moved; the stored translation is still current.
+		body := joinIncremental(source, previous, matches, nil)
+		return body, incrementalOutput(record, matches, body), true, nil
+	}
+
+	translatedRuns := make([][]string, len(runs))
+	pendingFront := frontChanged
+	requests := runs
+	if len(requests) == 0 {
+		// Frontmatter changed but no body block did.
+		requests = []blockRun{{}}
+	}
+	for i, run := range requests {
+		frontTemplate, markers := "", []frontmatterMarker(nil)
+		if pendingFront {
+			frontTemplate, markers = buildFrontmatterTemplate(front)
+		}
+		runSource := markdownBlocks{Blocks: source.Blocks[run.Start:run.Stop], Seps: source.Seps[run.Start:run.Stop]}
+		input := formatContextBlock(runContext(source, previous, matches, run)) + formatTaggedDocument(frontTemplate, strings.TrimRight(runSource.join(), "\n"))
+		translatedFront, translatedBody, err := translateTaggedDoc(ctx, translator, input, runSource.join(), srcLang, tgtLang, promptHints{})
+		if err != nil {
+			return "", nil, false, err
+		}
+		translatedBody, err = restoreHTMLSpans(ctx, translator, opts.TM, relPath, runSource.join(), translatedBody, srcLang, tgtLang, opts.Components)
+		if err != nil {
+			return "", nil, false, err
+		}
+		if pendingFront {
+			if err := applyFrontmatterTranslations(front, markers, translatedFront); err != nil {
+				return "", nil, false, fmt.Errorf("frontmatter translation failed: %w", err)
+			}
+			pendingFront = false
+		}
+		if run.Stop == run.Start {
+			continue
+		}
+		runBlocks := splitMarkdownBlocks(translatedBody)
+		if len(runBlocks.Blocks) != run.Stop-run.Start {
+			return "", nil, false, nil
+		}
+		translatedRuns[i] = runBlocks.Blocks
+	}
+
+	for key := range frontData {
+		delete(frontData, key)
+	}
+	for key, value := range front {
+		frontData[key] = value
+	}
+	body := joinIncremental(source, previous, matches, func(index int) string {
+		for i, run := range runs {
+			if index >= run.Start && index < run.Stop {
+				return translatedRuns[i][index-run.Start]
+			}
+		}
+		return ""
+	})
+	return body, incrementalOutput(record, matches, body), true, nil
+}
+
+// incrementalOutput returns the machine output hashes for an incremental
+// translation. Reused blocks keep the hash recorded when the model produced
+// them, so a human edit made since then is still seen by capture.
+func incrementalOutput(record BlockRecord, matches []int, body string) []string {
+	output := splitMarkdownBlocks(body).hashes()
+	if len(output) != len(matches) || len(record.Output) != len(record.Blocks) {
+		return output
+	}
+	for i, match := range matches {
+		if match >= 0 {
+			output[i] = record.Output[match]
+		}
+	}
+	return output
+}
+
+// alignBlocks matches new block hashes against the recorded ones with a
+// longest common subsequence, returning the old index for each new block or
+// -1 when the block is new or changed.
+func alignBlocks(oldHashes, newHashes []string) []int {
+	rows, cols := len(oldHashes), len(newHashes)
+	lcs := make([][]int, rows+1)
+	for i := range lcs {
+		lcs[i] = make([]int, cols+1)
+	}
+	for i := rows - 1; i >= 0; i-- {
+		for j := cols - 1; j >= 0; j-- {
+			if oldHashes[i] == newHashes[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	matches := make([]int, cols)
+	for j := range matches {
+		matches[j] = -1
+	}
+	i, j := 0, 0
+	for i < rows && j < cols {
+		switch {
+		case oldHashes[i] == newHashes[j]:
+			matches[j] = i
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+func changedRuns(matches []int) []blockRun {
+	var runs []blockRun
+	for i := 0; i < len(matches); i++ {
+		if matches[i] >= 0 {
+			continue
+		}
+		start := i
+		for i < len(matches) && matches[i] < 0 {
+			i++
+		}
+		runs = append(runs, blockRun{Start: start, Stop: i})
+	}
+	return runs
+}
+
+// referenceContext is sent ahead of a request in a <context> block: source
+// text paired with an approved translation of it, and terminology the
+// translation must follow.
+type referenceContext struct {
+	Source      []string
+	Translation []string
+	Terminology []string
+}
+
+// runContext returns the unchanged neighbours of a run with their existing
+// translations, so the model keeps terminology consistent with them.
+func runContext(source, previous markdownBlocks, matches []int, run blockRun) referenceContext {
+	var ctx referenceContext
+	for _, index := range []int{run.Start - 1, run.Stop} {
+		if index < 0 || index >= len(matches) || matches[index] < 0 {
+			continue
+		}
+		ctx.Source = append(ctx.Source, strings.TrimSpace(source.Blocks[index]))
+		ctx.Translation = append(ctx.Translation, strings.TrimSpace(previous.Blocks[matches[index]]))
+	}
+	return ctx
+}
+
+func formatContextBlock(ctx referenceContext) string {
+	if len(ctx.Source) == 0 && len(ctx.Terminology) == 0 {
+		return ""
+	}
+	var block strings.Builder
+	block.WriteString(contextTagStart + "\n")
+	if len(ctx.Source) > 0 {
+		fmt.Fprintf(&block, "<source>\n%s\n</source>\n<translation>\n%s\n</translation>\n",
+			strings.Join(ctx.Source, "\n\n"), strings.Join(ctx.Translation, "\n\n"))
+	}
+	if len(ctx.Terminology) > 0 {
+		fmt.Fprintf(&block, "<terminology>\n%s\n</terminology>\n", strings.Join(ctx.Terminology, "\n"))
+	}
+	block.WriteString(contextTagEnd + "\n")
+	return block.String()
+}
+
+// stripContextBlock drops a leading <context> block that a model echoed back.
+func stripContextBlock(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, contextTagStart) {
+		return text
+	}
+	end := strings.Index(trimmed, contextTagEnd)
+	if end == -1 {
+		return text
+	}
+	return strings.TrimLeft(trimmed[end+len(contextTagEnd):], "\n")
+}
+
+func joinIncremental(source, previous markdownBlocks, matches []int, translated func(int) string) string {
+	out := markdownBlocks{Lead: source.Lead, Seps: source.Seps, Blocks: make([]string, len(source.Blocks))}
+	for i := range source.Blocks {
+		if matches[i] >= 0 {
+			out.Blocks[i] = previous.Blocks[matches[i]]
+			continue
+		}
+		out.Blocks[i] = translated(i)
+	}
+	return out.join()
+}
+
+func cloneFrontData(data map[string]any) map[string]any {
+	clone := make(map[string]any, len(data))
+	for key, value := range data {
+		if list, ok := value.([]any); ok {
+			value = append([]any(nil), list...)
+		}
+		clone[key] = value
+	}
+	return clone
+}
+
+// copyTranslatedFront takes the translated frontmatter fields from an
+// existing translation.
+func copyTranslatedFront(front map[string]any, existingFront string) error {
+	existing := map[string]any{}
+	if err := yaml.Unmarshal([]byte(existingFront), &existing); err != nil {
+		return err
+	}
+	for _, field := range []string{"summary", "title", "read_when"} {
+		if _, ok := front[field]; !ok {
+			continue
+		}
+		value, ok := existing[field]
+		if !ok {
+			return fmt.Errorf("existing translation lacks %s", field)
+		}
+		front[field] = value
+	}
+	return nil
+}
+
+// recordDocBlocks stores the source block hashes for a machine translation
+// when its blocks line up one to one with the source; otherwise the record is
+// dropped and the next change triggers a full translation. output overrides
+// the machine output hashes computed from translatedBody.
+func recordDocBlocks(index *BlockIndex, relPath, sourceHash, sourceFront string, source markdownBlocks, translatedBody string, output []string) {
+	if output == nil {
+		output = splitMarkdownBlocks(translatedBody).hashes()
+	}
+	if len(output) != len(source.Blocks) {
+		index.Delete(relPath)
+		return
+	}
+	index.Put(BlockRecord{
+		SourcePath: relPath,
+		SourceHash: sourceHash,
+		FrontHash:  hashText(sourceFront),
+		Blocks:     source.hashes(),
+		Output:     output,
+		UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -17,17 +17,49 @@ const (
 	bodyTagEnd          = "</body>"
 )
 
-func processFileDoc(ctx context.Context, translator *PiTranslator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
-	absPath, relPath, err := resolveDocsPath(docsRoot, filePath)
-	if err != nil {
-		return false, err
+type docOptions struct {
+	// Incremental retranslates only the blocks that changed since the last
+	// run, reusing the rest of the existing translation.
+	Incremental bool
+	// ChunkTokens is the per-request token budget; longer bodies are split at
+	// H2/H3 headings. 0 sends every doc in one request.
+	ChunkTokens int
+	// ChunkParallel bounds how many chunks of one doc are translated at once,
+	// using extra translators from Spares.
+	ChunkParallel int
+	Spares        *spareTranslators
+	// Glossary checks the translated body; a full translation with
+	// violations may be retried once. nil skips the check.
+	Glossary *glossaryChecker
+	// Links points doc links at translated pages; nil leaves them alone.
+	Links *linkLocalizer
+	// Sources shares parsed source pages between languages; nil parses
+	// every page on its own.
+	Sources *sourceCache
+	// QA back-translates and checks the translated blocks; nil skips it.
+	QA *qaChecker
+	// Components lists the component attributes to translate. The HTML the
+	// model returns is replaced by the source HTML translated the segment mode
+	// way, attribute values and HTML text going through TM; a nil TM
+	// translates them uncached.
+	Components componentAttrs
+	TM         *TranslationMemory
+}
+
+// docModeProcessor adapts processFileDoc to the doc runners. The block index
+// and overrides are shared by all workers.
+func docModeProcessor(blocks *BlockIndex, overrides *OverrideStore, opts docOptions) docProcessor {
+	return func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
+		return processFileDoc(ctx, translator, blocks, overrides, docsRoot, filePath, srcLang, tgtLang, overwrite, opts)
 	}
+}
 
-	content, err := os.ReadFile(absPath)
+func processFileDoc(ctx context.Context, translator Translator, blocks *BlockIndex, overrides *OverrideStore, docsRoot, filePath, srcLang, tgtLang string, overwrite bool, opts docOptions) (bool, error) {
+	source, err := opts.Sources.Load(docsRoot, filePath)
 	if err != nil {
 		return false, err
 	}
-	currentHash := hashBytes(content)
+	relPath, content, currentHash := source.RelPath, source.Content, source.Hash
 
 	outputPath := filepath.Join(docsRoot, tgtLang, relPath)
 	if !overwrite {
@@ -36,37 +68,65 @@ func processFileDoc(ctx context.Context, translator *PiTranslator, docsRoot, fil
 			return false, err
 		}
 		if skip {
+			// Up to date, but pages it links to may have been translated
+			// since.
+			if _, err := opts.Links.Relink(blocks, relPath); err != nil {
+				return false, err
+			}
 			return true, nil
 		}
 	}
 
-	sourceFront, sourceBody := splitFrontMatter(string(content))
-	frontData := map[string]any{}
-	if strings.TrimSpace(sourceFront) != "" {
-		if err := yaml.Unmarshal([]byte(sourceFront), &frontData); err != nil {
-			return false, fmt.Errorf("frontmatter parse failed for %s: %w", relPath, err)
-		}
+	if err := keepHandEdits(overrides, blocks, docsRoot, relPath, srcLang, tgtLang); err != nil {
+		return false, err
 	}
-	frontTemplate, markers := buildFrontmatterTemplate(frontData)
-	taggedInput := formatTaggedDocument(frontTemplate, sourceBody)
 
-	translatedDoc, err := translator.TranslateRaw(ctx, taggedInput, srcLang, tgtLang)
+	sourceFront, sourceBody, sourceBlocks := source.Front, source.Body, source.Blocks
+	frontData, err := source.FrontData()
 	if err != nil {
-		return false, fmt.Errorf("translate failed (%s): %w", relPath, err)
+		return false, err
 	}
 
-	translatedFront, translatedBody, err := parseTaggedDocument(translatedDoc)
-	if err != nil {
-		return false, fmt.Errorf("tagged output invalid for %s: %w", relPath, err)
+	translatedBody := ""
+	var machineOutput []string
+	incremental := false
+	if !overwrite && opts.Incremental {
+		translatedBody, machineOutput, incremental, err = translateDocIncremental(ctx, translator, blocks, relPath, outputPath, sourceFront, frontData, sourceBlocks, srcLang, tgtLang, opts)
+		if err != nil {
+			return false, fmt.Errorf("incremental translate failed (%s): %w", relPath, err)
+		}
 	}
-	if sourceFront != "" && strings.TrimSpace(translatedFront) == "" {
-		return false, fmt.Errorf("translation removed frontmatter for %s", relPath)
+	if !incremental {
+		sourceFrontData := cloneFrontData(frontData)
+		translatedBody, err = translateDocFull(ctx, translator, relPath, sourceFront, sourceBody, frontData, srcLang, tgtLang, opts, promptHints{})
+		if err != nil {
+			return false, err
+		}
+		if violations := opts.Glossary.CheckBlocks(sourceBlocks, translatedBody); opts.Glossary.Retry(violations) {
+			retryFront := cloneFrontData(sourceFrontData)
+			retried, err := translateDocFull(ctx, translator, relPath, sourceFront, sourceBody, retryFront, srcLang, tgtLang, opts, promptHints{Corrections: glossaryCorrections(violations)})
+			if err != nil {
+				return false, fmt.Errorf("glossary retry failed (%s): %w", relPath, err)
+			}
+			if len(opts.Glossary.CheckBlocks(sourceBlocks, retried)) < len(violations) {
+				translatedBody, frontData = retried, retryFront
+			}
+		}
 	}
-	if err := applyFrontmatterTranslations(frontData, markers, translatedFront); err != nil {
-		return false, fmt.Errorf("frontmatter translation failed for %s: %w", relPath, err)
+	logGlossaryViolations(relPath, opts.Glossary.CheckBlocks(sourceBlocks, translatedBody))
+	if err := opts.QA.Check(ctx, relPath, blockPairs(sourceBlocks, translatedBody)); err != nil {
+		return false, err
+	}
+	localizedBody := opts.Links.Localize(relPath, sourceBody, anchorHeadings(sourceBody, translatedBody))
+	machineOutput = localizedOutput(machineOutput, translatedBody, localizedBody)
+	translatedBody = localizedBody
+	recordDocBlocks(blocks, relPath, currentHash, sourceFront, sourceBlocks, translatedBody, machineOutput)
+	translatedBody, applied := applyOverrides(overrides, relPath, sourceBlocks, translatedBody)
+	if err := validateHeadingIDs(translatedBody); err != nil {
+		return false, fmt.Errorf("%s: %w", relPath, err)
 	}
 
-	updatedFront, err := encodeFrontMatter(frontData, relPath, content)
+	updatedFront, err := encodeFrontMatter(frontData, relPath, content, translator.Provider(), translator.Model(), applied)
 	if err != nil {
 		return false, err
 	}
@@ -79,6 +139,30 @@ func processFileDoc(ctx context.Context, translator *PiTranslator, docsRoot, fil
 	return false, os.WriteFile(outputPath, []byte(output), 0o644)
 }
 
+// translateDocFull translates the whole doc, chunked when it exceeds the token
+// budget, applying the frontmatter translations to frontData and returning
+// the body.
+func translateDocFull(ctx context.Context, translator Translator, relPath, sourceFront, sourceBody string, frontData map[string]any, srcLang, tgtLang string, opts docOptions, hints promptHints) (string, error) {
+	frontTemplate, markers := buildFrontmatterTemplate(frontData)
+	chunks := planDocChunks(sourceBody, opts.ChunkTokens)
+
+	translatedFront, translatedBody, err := translateDocChunks(ctx, translator, opts, frontTemplate, chunks, srcLang, tgtLang, hints)
+	if err != nil {
+		return "", fmt.Errorf("translate failed (%s): %w", relPath, err)
+	}
+	if sourceFront != "" && strings.TrimSpace(translatedFront) == "" {
+		return "", fmt.Errorf("translation removed frontmatter for %s", relPath)
+	}
+	if err := applyFrontmatterTranslations(frontData, markers, translatedFront); err != nil {
+		return "", fmt.Errorf("frontmatter translation failed for %s: %w", relPath, err)
+	}
+	translatedBody, err = restoreHTMLSpans(ctx, translator, opts.TM, relPath, sourceBody, translatedBody, srcLang, tgtLang, opts.Components)
+	if err != nil {
+		return "", fmt.Errorf("html translation failed (%s): %w", relPath, err)
+	}
+	return translatedBody, nil
+}
+
 func formatTaggedDocument(frontMatter, body string) string {
 	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s", frontmatterTagStart, frontMatter, frontmatterTagEnd, bodyTagStart, body, bodyTagEnd)
 }
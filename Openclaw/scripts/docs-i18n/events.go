@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runEvent is one line of the -events stream. Fields that don't apply to an
+// event are left out.
+type runEvent struct {
+	Event string `json:"event"`
+	Time  string `json:"time"`
+
+	// Run fields (run_start, run_end).
+	Mode     string   `json:"mode,omitempty"`
+	Provider string   `json:"provider,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Langs    []string `json:"langs,omitempty"`
+	Parallel int      `json:"parallel,omitempty"`
+	Files    int      `json:"files,omitempty"`
+	Jobs     int      `json:"jobs,omitempty"`
+	// Status is how the run ended: completed, budget, failed or interrupted.
+	Status     string `json:"status,omitempty"`
+	Processed  *int   `json:"processed,omitempty"`
+	Skipped    *int   `json:"skipped,omitempty"`
+	PreSkipped *int   `json:"pre_skipped,omitempty"`
+	Remaining  *int   `json:"remaining,omitempty"`
+
+	// File fields.
+	Lang   string `json:"lang,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Index  int    `json:"index,omitempty"`
+	Total  int    `json:"total,omitempty"`
+	Worker int    `json:"worker,omitempty"`
+	// Attempt is the attempt that failed validation, or the one a retry
+	// starts.
+	Attempt    int   `json:"attempt,omitempty"`
+	DelayMS    int64 `json:"delay_ms,omitempty"`
+	DurationMS int64 `json:"duration_ms,omitempty"`
+
+	Usage *tokenUsage `json:"usage,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// eventLog writes progress events as JSON lines next to the human log, for
+// CI and dashboards. A nil log writes nothing.
+type eventLog struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+	failed bool
+}
+
+// openEventLog opens the event stream for -events. An empty format disables
+// it; path "-" writes to stdout, any other path is appended to.
+func openEventLog(format, path string) (*eventLog, error) {
+	switch format {
+	case "":
+		return nil, nil
+	case "jsonl":
+	default:
+		return nil, fmt.Errorf("unknown -events format: %s", format)
+	}
+	if path == "" || path == "-" {
+		return &eventLog{out: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLog{out: file, closer: file}, nil
+}
+
+func (l *eventLog) Emit(event runEvent) {
+	if l == nil {
+		return
+	}
+	if event.Time == "" {
+		event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	payload, err := json.Marshal(event)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == nil {
+		_, err = l.out.Write(append(payload, '\n'))
+	}
+	if err != nil && !l.failed {
+		// Events are a side channel; losing them must not stop the run.
+		l.failed = true
+		log.Printf("docs-i18n: event write failed: %v", err)
+	}
+}
+
+func (l *eventLog) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// FileStart emits file_start for a job; worker is 0 in a sequential run.
+func (l *eventLog) FileStart(job docJob, worker int) {
+	l.Emit(runEvent{Event: "file_start", Lang: job.target.Lang, Path: job.rel, Index: job.index, Total: len(job.target.Queue), Worker: worker})
+}
+
+// FileResult emits file_done, file_skipped or file_failed for a finished
+// job.
+func (l *eventLog) FileResult(result docResult) {
+	job := result.job
+	event := runEvent{
+		Event:      "file_done",
+		Lang:       job.target.Lang,
+		Path:       job.rel,
+		Index:      job.index,
+		Total:      len(job.target.Queue),
+		Worker:     result.worker,
+		DurationMS: result.duration.Milliseconds(),
+	}
+	switch {
+	case result.err != nil:
+		event.Event, event.Error = "file_failed", result.err.Error()
+	case result.skipped:
+		event.Event = "file_skipped"
+	}
+	if result.err == nil {
+		usage := result.usage
+		event.Usage = &usage
+	}
+	l.Emit(event)
+}
+
+type eventFileKey struct{}
+
+type eventFile struct {
+	log    *eventLog
+	lang   string
+	path   string
+	worker int
+}
+
+// track attaches a job to ctx, so events raised while translating it
+// (retries, validation failures) name the file.
+func (l *eventLog) track(ctx context.Context, job docJob, worker int) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, eventFileKey{}, &eventFile{log: l, lang: job.target.Lang, path: job.rel, worker: worker})
+}
+
+// emitFileEvent emits event for the file attached to ctx, if any.
+func emitFileEvent(ctx context.Context, event runEvent) {
+	file, _ := ctx.Value(eventFileKey{}).(*eventFile)
+	if file == nil {
+		return
+	}
+	event.Lang, event.Path, event.Worker = file.lang, file.path, file.worker
+	file.log.Emit(event)
+}
+
+// isValidationError reports whether err is a model reply that failed
+// validation, as opposed to a failed request.
+func isValidationError(err error) bool {
+	if errors.Is(err, errStructureMismatch) || errors.Is(err, errEmptyTranslation) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "placeholder missing") || strings.Contains(message, "tagged output invalid")
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// brokenBatchTranslator answers every batch request with text the batch
+// parser can't use, so each segment falls back to its own request.
+type brokenBatchTranslator struct {
+	*FakeTranslator
+}
+
+func (t brokenBatchTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return "I can't help with that.", nil
+}
+
+func TestRunDocSequentialEvents(t *testing.T) {
+	docsRoot := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.md", "b.md"} {
+		file := filepath.Join(docsRoot, name)
+		if err := os.WriteFile(file, []byte("# Title\n\nFirst paragraph.\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := segmentProcessor(tm, nil, nil, segmentOptions{BatchSize: defaultBatchSize})
+	process := func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
+		if filepath.Base(filePath) == "b.md" {
+			return false, errors.New("boom")
+		}
+		return segments(ctx, translator, docsRoot, filePath, srcLang, tgtLang, overwrite)
+	}
+	target := &langTarget{Lang: "zh-CN", Process: process, Queue: files}
+	translators := translatorPool{"zh-CN": brokenBatchTranslator{NewFakeTranslator()}}
+
+	var out bytes.Buffer
+	events := &eventLog{out: &out}
+	_, _, err = runDocSequential(context.Background(), buildJobs(docsRoot, files, []*langTarget{target}), translators, docsRoot, "en", false, nil, nil, events)
+	if err == nil {
+		t.Fatal("failing file did not fail the run")
+	}
+
+	var got []string
+	var done runEvent
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event runEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("bad event line %q: %v", line, err)
+		}
+		if event.Time == "" || event.Lang != "zh-CN" {
+			t.Errorf("event missing time or language: %s", line)
+		}
+		got = append(got, event.Event+" "+event.Path)
+		if event.Event == "file_done" {
+			done = event
+		}
+	}
+	want := []string{
+		"file_start a.md",
+		"validation_failure a.md",
+		"validation_failure a.md",
+		"file_done a.md",
+		"file_start b.md",
+		"file_failed b.md",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	if done.Index != 1 || done.Total != 2 || done.Usage == nil || done.Usage.Calls != 2 {
+		t.Errorf("file_done = %+v", done)
+	}
+}
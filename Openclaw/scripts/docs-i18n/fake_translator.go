@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// FakeTranslator is an offline backend that pseudo-localizes instead of
+// calling a model. It goes through the same masking and placeholder
+// validation as the real backends, so it exercises the whole pipeline
+// deterministically.
+type FakeTranslator struct {
+	calls atomic.Int64
+}
+
+func NewFakeTranslator() *FakeTranslator {
+	return &FakeTranslator{}
+}
+
+func (t *FakeTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateMasked(ctx, t.prompt, hints, core)
+	})
+}
+
+func (t *FakeTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateRaw(ctx, t.prompt, hints, core)
+	})
+}
+
+func (t *FakeTranslator) Provider() string {
+	return "fake"
+}
+
+func (t *FakeTranslator) Model() string {
+	return "pseudo"
+}
+
+func (t *FakeTranslator) Profile() string {
+	return ""
+}
+
+func (t *FakeTranslator) Close() {}
+
+// Calls reports how many prompts reached the backend.
+func (t *FakeTranslator) Calls() int {
+	return int(t.calls.Load())
+}
+
+func (t *FakeTranslator) prompt(ctx context.Context, message string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	t.calls.Add(1)
+	recordUsage(ctx, tokenUsage{Calls: 1})
+	message = stripContextBlock(message)
+	if segmentBatchRe.MatchString(message) {
+		return segmentBatchRe.ReplaceAllStringFunc(message, func(block string) string {
+			match := segmentBatchRe.FindStringSubmatch(block)
+			return fmt.Sprintf("<seg id=\"%s\">%s%s", match[1], pseudoLocalize(match[2]), segmentBatchTagEnd)
+		}), nil
+	}
+	if !strings.Contains(message, frontmatterTagStart) {
+		return pseudoLocalize(message), nil
+	}
+	front, body, err := parseTaggedDocument(message)
+	if err != nil {
+		return "", err
+	}
+	body, err = translateHTMLBlocks(ctx, t, nil, "", body, "", "", nil)
+	if err != nil {
+		return "", err
+	}
+	body, err = pseudoLocalizeBody(body)
+	if err != nil {
+		return "", err
+	}
+	return formatTaggedDocument(pseudoLocalizeMarkers(front), body), nil
+}
@@ -1,15 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 )
 
 type GlossaryEntry struct {
 	Source string `json:"source"`
 	Target string `json:"target"`
+	// IgnoreCase matches Source (and Target, Forbidden in the output) without
+	// regard to case.
+	IgnoreCase bool `json:"ignore_case,omitempty"`
+	// WholeWord matches Source only at word boundaries, so "Pi" doesn't hit
+	// "Pipeline"; it is the default, and false matches anywhere.
+	WholeWord *bool `json:"whole_word,omitempty"`
+	// DoNotTranslate keeps Source as is; Target may be left empty.
+	DoNotTranslate bool `json:"do_not_translate,omitempty"`
+	// Forbidden lists renderings of Source the target language must not use.
+	Forbidden []string `json:"forbidden,omitempty"`
+	// Notes is guidance passed to the model with the entry.
+	Notes string `json:"notes,omitempty"`
+}
+
+// want returns the text the translation must contain for the entry.
+func (entry GlossaryEntry) want() string {
+	if entry.DoNotTranslate || strings.TrimSpace(entry.Target) == "" {
+		return entry.Source
+	}
+	return entry.Target
 }
 
 func LoadGlossary(path string) ([]GlossaryEntry, error) {
@@ -27,3 +52,211 @@ func LoadGlossary(path string) ([]GlossaryEntry, error) {
 
 	return entries, nil
 }
+
+// glossaryViolation is a glossary term that appeared in the source without
+// its mandated rendering in the translation, or with a forbidden one.
+type glossaryViolation struct {
+	Entry     GlossaryEntry
+	Forbidden string
+}
+
+func (v glossaryViolation) String() string {
+	if v.Forbidden != "" {
+		return fmt.Sprintf("%q rendered as forbidden %q", v.Entry.Source, v.Forbidden)
+	}
+	return fmt.Sprintf("%q without %q", v.Entry.Source, v.Entry.want())
+}
+
+// correction is the instruction sent with a retry.
+func (v glossaryViolation) correction() string {
+	switch {
+	case v.Forbidden != "":
+		return fmt.Sprintf("Do not translate %q as %q; use %q.", v.Entry.Source, v.Forbidden, v.Entry.want())
+	case v.Entry.DoNotTranslate:
+		return fmt.Sprintf("Keep %q untranslated.", v.Entry.Source)
+	default:
+		return fmt.Sprintf("Translate %q as %q.", v.Entry.Source, v.Entry.want())
+	}
+}
+
+type glossaryTerm struct {
+	entry  GlossaryEntry
+	source *regexp.Regexp
+}
+
+// glossaryChecker verifies that translations use the glossary. A nil checker
+// checks nothing.
+type glossaryChecker struct {
+	terms []glossaryTerm
+	// retry retranslates output with violations once, sending the
+	// corrections along with the request.
+	retry bool
+}
+
+func newGlossaryChecker(entries []GlossaryEntry, retry bool) *glossaryChecker {
+	checker := &glossaryChecker{retry: retry}
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.Source) == "" || strings.TrimSpace(entry.want()) == "" {
+			continue
+		}
+		pattern := regexp.QuoteMeta(entry.Source)
+		if entry.WholeWord == nil || *entry.WholeWord {
+			// \b only where the term starts or ends with a word character:
+			// "C++" or "网关" has no boundary to match there.
+			if isWordByte(entry.Source[0]) {
+				pattern = `\b` + pattern
+			}
+			if isWordByte(entry.Source[len(entry.Source)-1]) {
+				pattern += `\b`
+			}
+		}
+		if entry.IgnoreCase {
+			pattern = `(?i)` + pattern
+		}
+		checker.terms = append(checker.terms, glossaryTerm{entry: entry, source: regexp.MustCompile(pattern)})
+	}
+	// Longer terms first, so "Skills config" claims its text before "Skills".
+	sort.SliceStable(checker.terms, func(i, j int) bool {
+		return len(checker.terms[i].entry.Source) > len(checker.terms[j].entry.Source)
+	})
+	return checker
+}
+
+// isWordByte reports whether c is a word character as regexp \b sees it.
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+var (
+	glossaryInlineCodeRe = regexp.MustCompile("`[^`\n]*`")
+	glossaryLinkDestRe   = regexp.MustCompile(`\]\([^)\s]*\)`)
+)
+
+// glossaryProse drops fenced code, code spans and link destinations, which
+// are never translated.
+func glossaryProse(text string) string {
+	for _, block := range extractFencedBlocks(text) {
+		text = strings.Replace(text, block, "", 1)
+	}
+	text = glossaryInlineCodeRe.ReplaceAllString(text, " ")
+	return glossaryLinkDestRe.ReplaceAllString(text, "]")
+}
+
+// Check returns the glossary violations of translated against source.
+func (checker *glossaryChecker) Check(source, translated string) []glossaryViolation {
+	if checker == nil || len(checker.terms) == 0 {
+		return nil
+	}
+	source = glossaryProse(source)
+	translated = glossaryProse(translated)
+	var covered [][]int
+	var violations []glossaryViolation
+	for _, term := range checker.terms {
+		present := false
+		for _, span := range term.source.FindAllStringIndex(source, -1) {
+			if !spanCovered(covered, span) {
+				present = true
+			}
+			covered = append(covered, span)
+		}
+		if !present {
+			continue
+		}
+		if !containsTerm(translated, term.entry.want(), term.entry.IgnoreCase) {
+			violations = append(violations, glossaryViolation{Entry: term.entry})
+			continue
+		}
+		for _, forbidden := range term.entry.Forbidden {
+			if strings.TrimSpace(forbidden) != "" && containsTerm(translated, forbidden, term.entry.IgnoreCase) {
+				violations = append(violations, glossaryViolation{Entry: term.entry, Forbidden: forbidden})
+			}
+		}
+	}
+	return violations
+}
+
+// CheckBlocks checks a translated body block by block against its source,
+// falling back to the whole body when the blocks don't line up.
+func (checker *glossaryChecker) CheckBlocks(source markdownBlocks, translatedBody string) []glossaryViolation {
+	if checker == nil || len(checker.terms) == 0 {
+		return nil
+	}
+	translated := splitMarkdownBlocks(translatedBody)
+	if len(translated.Blocks) != len(source.Blocks) {
+		return checker.Check(source.join(), translatedBody)
+	}
+	var violations []glossaryViolation
+	seen := map[string]bool{}
+	for i := range source.Blocks {
+		for _, violation := range checker.Check(source.Blocks[i], translated.Blocks[i]) {
+			if key := violation.String(); !seen[key] {
+				seen[key] = true
+				violations = append(violations, violation)
+			}
+		}
+	}
+	return violations
+}
+
+// Retry reports whether output with these violations should be
+// retranslated.
+func (checker *glossaryChecker) Retry(violations []glossaryViolation) bool {
+	return checker != nil && checker.retry && len(violations) > 0
+}
+
+func spanCovered(covered [][]int, span []int) bool {
+	for _, other := range covered {
+		if other[0] <= span[0] && span[1] <= other[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTerm(text, term string, ignoreCase bool) bool {
+	if ignoreCase {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(term))
+	}
+	return strings.Contains(text, term)
+}
+
+func glossaryCorrections(violations []glossaryViolation) []string {
+	corrections := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		corrections = append(corrections, violation.correction())
+	}
+	return corrections
+}
+
+func logGlossaryViolations(relPath string, violations []glossaryViolation) {
+	for _, violation := range violations {
+		log.Printf("docs-i18n: glossary %s: %s", relPath, violation)
+	}
+}
+
+// enforceGlossary checks freshly translated segments, retranslating the ones
+// with violations when the checker retries and keeping whichever output is
+// closer to the glossary. Remaining violations are logged.
+func enforceGlossary(ctx context.Context, translator Translator, checker *glossaryChecker, relPath string, segments []*Segment, srcLang, tgtLang string) error {
+	if checker == nil {
+		return nil
+	}
+	var remaining []glossaryViolation
+	for _, seg := range segments {
+		violations := checker.Check(seg.Text, seg.Translated)
+		if checker.Retry(violations) {
+			hints := promptHints{References: seg.References, Corrections: glossaryCorrections(violations)}
+			retried, err := translator.Translate(ctx, seg.Text, srcLang, tgtLang, hints)
+			if err != nil {
+				return err
+			}
+			if again := checker.Check(seg.Text, retried); len(again) < len(violations) {
+				seg.Translated = retried
+				violations = again
+			}
+		}
+		remaining = append(remaining, violations...)
+	}
+	logGlossaryViolations(relPath, remaining)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGlossaryCheck(t *testing.T) {
+	checker := newGlossaryChecker([]GlossaryEntry{
+		{Source: "Gateway", Target: "Gateway 网关", Forbidden: []string{"网关服务"}},
+		{Source: "Skills", Target: "Skills"},
+		{Source: "Skills config", Target: "Skills 配置"},
+		{Source: "sandbox", Target: "沙箱", IgnoreCase: true},
+		{Source: "OpenClaw", DoNotTranslate: true},
+		{Source: "Pi", Target: "Pi"},
+		{Source: "config", Target: "配置", WholeWord: new(bool)},
+	}, false)
+
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		want       []string
+	}{
+		{"target used", "Restart the Gateway.", "重启 Gateway 网关。", nil},
+		{"target missing", "Restart the Gateway.", "重启网关。", []string{`"Gateway" without "Gateway 网关"`}},
+		{"forbidden variant", "Restart the Gateway.", "重启 Gateway 网关（网关服务）。", []string{`"Gateway" rendered as forbidden "网关服务"`}},
+		{"longer term wins", "Edit the Skills config.", "编辑 Skills 配置。", nil},
+		{"ignore case", "Enable the Sandbox.", "启用沙盒。", []string{`"sandbox" without "沙箱"`}},
+		{"whole word", "Sandboxing is on.", "已开启隔离。", nil},
+		{"whole word by default", "The Pipeline runs.", "流水线运行。", nil},
+		{"whole word match", "Install Pi first.", "先安装派。", []string{`"Pi" without "Pi"`}},
+		{"whole word opt-out", "Edit the reconfiguration.", "编辑重新设置。", []string{`"config" without "配置"`}},
+		{"do not translate", "OpenClaw runs locally.", "开放之爪在本地运行。", []string{`"OpenClaw" without "OpenClaw"`}},
+		{"code ignored", "Run `openclaw gateway` and see [Gateway](/Gateway).", "运行 `openclaw gateway`，参见 [Gateway 网关](/Gateway)。", nil},
+		{"term only in code", "Set `Gateway` in config.", "在配置中设置 `Gateway`。", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range checker.Check(tt.source, tt.translated) {
+				got = append(got, violation.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// glossaryTranslator ignores the glossary unless the request carries
+// terminology corrections.
+type glossaryTranslator struct {
+	*FakeTranslator
+	corrections []string
+}
+
+func (t *glossaryTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	block := hints.block()
+	if strings.Contains(block, "<terminology>") {
+		t.corrections = append(t.corrections, block)
+		return "重启 Gateway 网关。", nil
+	}
+	return "重启网关。", nil
+}
+
+func TestEnforceGlossaryRetriesWithCorrections(t *testing.T) {
+	entries := []GlossaryEntry{{Source: "Gateway", Target: "Gateway 网关"}}
+	for _, retry := range []bool{false, true} {
+		translator := &glossaryTranslator{FakeTranslator: NewFakeTranslator()}
+		seg := &Segment{Text: "Restart the Gateway.", Translated: "重启网关。"}
+		if err := enforceGlossary(context.Background(), translator, newGlossaryChecker(entries, retry), "index.md", []*Segment{seg}, "en", "zh-CN"); err != nil {
+			t.Fatal(err)
+		}
+		if !retry {
+			if seg.Translated != "重启网关。" || len(translator.corrections) != 0 {
+				t.Fatalf("warn mode changed the segment: %q", seg.Translated)
+			}
+			continue
+		}
+		if seg.Translated != "重启 Gateway 网关。" {
+			t.Fatalf("Translated = %q, want the corrected retry", seg.Translated)
+		}
+		if len(translator.corrections) != 1 || !strings.Contains(translator.corrections[0], `Translate "Gateway" as "Gateway 网关".`) {
+			t.Fatalf("retry corrections = %q", translator.corrections)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata/golden")
+
+var generatedAtRe = regexp.MustCompile(`generated_at: "[^"]*"`)
+
+func TestSegmentModeGolden(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	translator := NewFakeTranslator()
+	for _, file := range fixtureFiles(t, docsRoot) {
+		if _, err := processFile(context.Background(), translator, tm, nil, nil, docsRoot, file, "en", "zh-CN", segmentOptions{BatchSize: defaultBatchSize}); err != nil {
+			t.Fatalf("processFile(%s): %v", file, err)
+		}
+	}
+	assertGoldenTree(t, filepath.Join(docsRoot, "zh-CN"), filepath.Join("testdata", "golden", "segment"))
+}
+
+func TestSegmentModeParallelGolden(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &langTarget{
+		Lang:    "zh-CN",
+		Config:  translatorConfig{Provider: "fake", SrcLang: "en", TgtLang: "zh-CN"},
+		Process: segmentProcessor(tm, nil, nil, segmentOptions{BatchSize: defaultBatchSize}),
+		Queue:   fixtureFiles(t, docsRoot),
+	}
+	jobs := buildJobs(docsRoot, target.Queue, []*langTarget{target})
+	processed, _, err := runDocParallel(context.Background(), jobs, docsRoot, "en", false, 4, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 2 {
+		t.Fatalf("processed = %d, want 2", processed)
+	}
+	assertGoldenTree(t, filepath.Join(docsRoot, "zh-CN"), filepath.Join("testdata", "golden", "segment"))
+}
+
+func TestSegmentModeReusesTranslationMemory(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	tmPath := filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl")
+	file := filepath.Join(docsRoot, "gateway", "configuration.md")
+	outputPath := filepath.Join(docsRoot, "zh-CN", "gateway", "configuration.md")
+
+	tm, err := LoadTranslationMemory(tmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := NewFakeTranslator()
+	if _, err := processFile(context.Background(), first, tm, nil, nil, docsRoot, file, "en", "zh-CN", segmentOptions{BatchSize: defaultBatchSize}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if first.Calls() == 0 {
+		t.Fatal("first run did not call the translator")
+	}
+	firstOutput := readNormalized(t, outputPath)
+
+	reloaded, err := LoadTranslationMemory(tmPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := NewFakeTranslator()
+	if _, err := processFile(context.Background(), second, reloaded, nil, nil, docsRoot, file, "en", "zh-CN", segmentOptions{BatchSize: defaultBatchSize}); err != nil {
+		t.Fatal(err)
+	}
+	if second.Calls() != 0 {
+		t.Fatalf("second run made %d translator calls, want 0", second.Calls())
+	}
+	if got := readNormalized(t, outputPath); got != firstOutput {
+		t.Fatalf("output changed on TM replay:\n%s\nwant:\n%s", got, firstOutput)
+	}
+}
+
+func TestDocModeGolden(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	translator := NewFakeTranslator()
+	blocks, err := LoadBlockIndex(filepath.Join(docsRoot, ".i18n", "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := fixtureFiles(t, docsRoot)
+	for _, file := range files {
+		skipped, err := processFileDoc(context.Background(), translator, blocks, nil, docsRoot, file, "en", "zh-CN", false, docOptions{Incremental: true})
+		if err != nil {
+			t.Fatalf("processFileDoc(%s): %v", file, err)
+		}
+		if skipped {
+			t.Fatalf("processFileDoc(%s) skipped a fresh doc", file)
+		}
+	}
+	assertGoldenTree(t, filepath.Join(docsRoot, "zh-CN"), filepath.Join("testdata", "golden", "doc"))
+
+	for _, file := range files {
+		skipped, err := processFileDoc(context.Background(), translator, blocks, nil, docsRoot, file, "en", "zh-CN", false, docOptions{Incremental: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !skipped {
+			t.Fatalf("processFileDoc(%s) retranslated an up-to-date doc", file)
+		}
+	}
+}
+
+func TestDocModeIncrementalReusesUnchangedBlocks(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	blocks, err := LoadBlockIndex(filepath.Join(docsRoot, ".i18n", "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourcePath := filepath.Join(docsRoot, "gateway", "configuration.md")
+	outputPath := filepath.Join(docsRoot, "zh-CN", "gateway", "configuration.md")
+	opts := docOptions{Incremental: true}
+	if _, err := processFileDoc(context.Background(), NewFakeTranslator(), blocks, nil, docsRoot, sourcePath, "en", "zh-CN", false, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark an unchanged block in the translation so reuse is observable, and
+	// edit one paragraph of the source.
+	translated := readNormalized(t, outputPath)
+	marked := strings.Replace(translated, "⟦Chángés táké éfféct áftér á réstárt.··········⟧", "REVIEWED", 1)
+	if marked == translated {
+		t.Fatal("fixture translation changed; update the test marker")
+	}
+	if err := os.WriteFile(outputPath, []byte(marked), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(source), "on startup.", "when it starts.", 1)
+	if err := os.WriteFile(sourcePath, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := processFileDoc(context.Background(), NewFakeTranslator(), blocks, nil, docsRoot, sourcePath, "en", "zh-CN", false, opts); err != nil {
+		t.Fatal(err)
+	}
+	got := readNormalized(t, outputPath)
+	if !strings.Contains(got, "> REVIEWED") {
+		t.Errorf("unchanged block was retranslated:\n%s", got)
+	}
+	if !strings.Contains(got, "⟦whén ít stárts.····⟧") {
+		t.Errorf("changed block was not retranslated:\n%s", got)
+	}
+	if !strings.Contains(got, "summary: ⟦Cónfígúré thé Gátéwáý wíth ópéncláw.jsón") {
+		t.Errorf("frontmatter translation was not carried over:\n%s", got)
+	}
+}
+
+func TestDocModeRelinksPagesTranslatedLater(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	blocks, err := LoadBlockIndex(filepath.Join(docsRoot, ".i18n", "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := LoadOverrideStore(filepath.Join(docsRoot, ".i18n", "zh-CN.overrides.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := langDirs{"zh-CN": true}
+	opts := docOptions{Incremental: true, Links: newLinkLocalizer(docsRoot, "zh-CN", dirs)}
+	indexPath := filepath.Join(docsRoot, "zh-CN", "index.md")
+	// index.md goes first, while the gateway page it links to is English only.
+	files := fixtureFiles(t, docsRoot)
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, err := processFileDoc(context.Background(), NewFakeTranslator(), blocks, store, docsRoot, files[i], "en", "zh-CN", false, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readNormalized(t, indexPath); !strings.Contains(got, "](/gateway/configuration)") {
+		t.Fatalf("link localized before its page was translated:\n%s", got)
+	}
+
+	if changed, err := relinkTranslations(docsRoot, "zh-CN", dirs, blocks); err != nil || changed != 1 {
+		t.Fatalf("relinkTranslations = %d, %v; want index.md relinked", changed, err)
+	}
+	if got := readNormalized(t, indexPath); !strings.Contains(got, "](/zh-CN/gateway/configuration)") {
+		t.Fatalf("link not relinked:\n%s", got)
+	}
+	if captured, err := captureOverrides(store, blocks, docsRoot, "index.md", "en", "zh-CN"); err != nil || captured != 0 {
+		t.Fatalf("capture took the relink for %d human edits (%v)", captured, err)
+	}
+}
+
+func TestPseudoModeMatchesDocModeStructure(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	translator := NewFakeTranslator()
+	for _, file := range fixtureFiles(t, docsRoot) {
+		if _, err := processFilePseudo(context.Background(), translator, nil, docsRoot, file, "en", "zh-CN", false, defaultComponentAttrs); err != nil {
+			t.Fatalf("processFilePseudo(%s): %v", file, err)
+		}
+	}
+	assertGoldenTree(t, filepath.Join(docsRoot, "zh-CN"), filepath.Join("testdata", "golden", "doc"))
+}
+
+func TestPseudoModeKeepsRealTranslations(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	outputPath := filepath.Join(docsRoot, "zh-CN", "index.md")
+	existing := "---\nx-i18n:\n  provider: pi\n---\n\n# 已翻译\n"
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(outputPath, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	skipped, err := processFilePseudo(context.Background(), NewFakeTranslator(), nil, docsRoot, filepath.Join(docsRoot, "index.md"), "en", "zh-CN", false, defaultComponentAttrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped {
+		t.Fatal("pseudo mode overwrote a real translation")
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != existing {
+		t.Fatalf("translation changed:\n%s", data)
+	}
+}
+
+func copyFixtureDocs(t *testing.T) string {
+	t.Helper()
+	docsRoot := filepath.Join(t.TempDir(), "docs")
+	err := filepath.WalkDir(filepath.Join("testdata", "docs"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Join("testdata", "docs"), path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(docsRoot, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docsRoot
+}
+
+func fixtureFiles(t *testing.T, docsRoot string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(docsRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".md" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func readNormalized(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return generatedAtRe.ReplaceAllString(string(data), `generated_at: "<generated_at>"`)
+}
+
+func assertGoldenTree(t *testing.T, outputRoot, goldenRoot string) {
+	t.Helper()
+	seen := map[string]bool{}
+	err := filepath.WalkDir(outputRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputRoot, path)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		got := readNormalized(t, path)
+		goldenPath := filepath.Join(goldenRoot, rel)
+		if *updateGolden {
+			if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+				return err
+			}
+			return os.WriteFile(goldenPath, []byte(got), 0o644)
+		}
+		want, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("missing golden file %s (run go test -update)", goldenPath)
+			return nil
+		}
+		if got != string(want) {
+			t.Errorf("%s mismatch:\n--- got ---\n%s\n--- want ---\n%s", rel, got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateGolden {
+		return
+	}
+	_ = filepath.WalkDir(goldenRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(goldenRoot, path)
+		if !seen[rel] {
+			t.Errorf("golden file %s was not produced", rel)
+		}
+		return nil
+	})
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
 
 	"github.com/yuin/goldmark"
@@ -19,7 +21,17 @@ type htmlReplacement struct {
 	Value string
 }
 
-func translateHTMLBlocks(ctx context.Context, translator *PiTranslator, body, srcLang, tgtLang string) (string, error) {
+// htmlSpan is a raw HTML block, or an inline tag when Inline is set, at
+// body[Start:Stop].
+type htmlSpan struct {
+	Start  int
+	Stop   int
+	Inline bool
+}
+
+// extractHTMLSpans finds the raw HTML blocks and inline tags of a body,
+// leaving out the headingAnchor of a translated heading.
+func extractHTMLSpans(body string) []htmlSpan {
 	source := []byte(body)
 	r := text.NewReader(source)
 	md := goldmark.New(
@@ -27,36 +39,108 @@ func translateHTMLBlocks(ctx context.Context, translator *PiTranslator, body, sr
 	)
 	doc := md.Parser().Parse(r)
 
-	replacements := make([]htmlReplacement, 0, 8)
-
+	var spans []htmlSpan
 	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
 			return ast.WalkContinue, nil
 		}
+		if raw, ok := n.(*ast.RawHTML); ok {
+			if isHeadingAnchor(raw, source) {
+				return ast.WalkSkipChildren, nil
+			}
+			for i := 0; i < raw.Segments.Len(); i++ {
+				segment := raw.Segments.At(i)
+				spans = append(spans, htmlSpan{Start: segment.Start, Stop: segment.Stop, Inline: true})
+			}
+			return ast.WalkSkipChildren, nil
+		}
 		block, ok := n.(*ast.HTMLBlock)
 		if !ok {
 			return ast.WalkContinue, nil
 		}
-		start, stop, ok := htmlBlockSpan(block, source)
-		if !ok {
-			return ast.WalkSkipChildren, nil
-		}
-		htmlText := string(source[start:stop])
-		translated, err := translateHTMLBlock(ctx, translator, htmlText, srcLang, tgtLang)
-		if err != nil {
-			return ast.WalkStop, err
+		if start, stop, ok := htmlBlockSpan(block, source); ok {
+			spans = append(spans, htmlSpan{Start: start, Stop: stop})
 		}
-		replacements = append(replacements, htmlReplacement{Start: start, Stop: stop, Value: translated})
 		return ast.WalkSkipChildren, nil
 	})
+	return spans
+}
 
-	if len(replacements) == 0 {
-		return body, nil
+// isHeadingAnchor reports whether raw is the headingAnchor of a heading.
+func isHeadingAnchor(raw *ast.RawHTML, source []byte) bool {
+	if _, ok := raw.Parent().(*ast.Heading); !ok || raw.Segments.Len() != 1 {
+		return false
 	}
+	segment := raw.Segments.At(0)
+	return anchorTagRe.Match(segment.Value(source))
+}
 
+// htmlSegmentID is the translation memory segment of the text in the HTML
+// blocks of relPath.
+func htmlSegmentID(relPath string) string {
+	return relPath + ":html"
+}
+
+// translateHTMLBlocks translates the text of raw HTML blocks, and the
+// allowlisted component attributes of HTML blocks and inline tags.
+func translateHTMLBlocks(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, body, srcLang, tgtLang string, components componentAttrs) (string, error) {
+	replacements, err := translateHTMLSpans(ctx, translator, tm, relPath, body, extractHTMLSpans(body), srcLang, tgtLang, components)
+	if err != nil {
+		return "", err
+	}
 	return applyHTMLReplacements(body, replacements), nil
 }
 
+// translateHTMLSpans returns the replacements for the spans of body whose
+// translation differs from the source. Text and attribute values go through
+// the translation memory under segment IDs of relPath.
+func translateHTMLSpans(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, body string, spans []htmlSpan, srcLang, tgtLang string, components componentAttrs) ([]htmlReplacement, error) {
+	replacements := make([]htmlReplacement, 0, len(spans))
+	for _, span := range spans {
+		source := body[span.Start:span.Stop]
+		translated, err := translateHTMLSpan(ctx, translator, tm, relPath, source, span.Inline, srcLang, tgtLang, components)
+		if err != nil {
+			return nil, err
+		}
+		if translated != source {
+			replacements = append(replacements, htmlReplacement{Start: span.Start, Stop: span.Stop, Value: translated})
+		}
+	}
+	return replacements, nil
+}
+
+func translateHTMLSpan(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, source string, inline bool, srcLang, tgtLang string, components componentAttrs) (string, error) {
+	if inline {
+		return components.translateTag(ctx, translator, tm, relPath, source, srcLang, tgtLang)
+	}
+	return translateHTMLBlock(ctx, translator, tm, relPath, source, srcLang, tgtLang, components)
+}
+
+// restoreHTMLSpans replaces the HTML of a doc mode translation with the
+// source HTML translated the way segment mode does it: only the text between
+// tags and the allowlisted component attributes, with JSX expressions kept
+// byte for byte. Whatever the model did to the HTML is dropped. Spans pair up
+// by position.
+func restoreHTMLSpans(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, sourceBody, translatedBody, srcLang, tgtLang string, components componentAttrs) (string, error) {
+	sourceSpans := extractHTMLSpans(sourceBody)
+	if len(sourceSpans) == 0 {
+		return translatedBody, nil
+	}
+	targetSpans := extractHTMLSpans(translatedBody)
+	if len(targetSpans) != len(sourceSpans) {
+		return "", fmt.Errorf("%w: %d html spans, want %d", errStructureMismatch, len(targetSpans), len(sourceSpans))
+	}
+	replacements := make([]htmlReplacement, 0, len(sourceSpans))
+	for i, span := range sourceSpans {
+		translated, err := translateHTMLSpan(ctx, translator, tm, relPath, sourceBody[span.Start:span.Stop], span.Inline, srcLang, tgtLang, components)
+		if err != nil {
+			return "", err
+		}
+		replacements = append(replacements, htmlReplacement{Start: targetSpans[i].Start, Stop: targetSpans[i].Stop, Value: translated})
+	}
+	return applyHTMLReplacements(translatedBody, replacements), nil
+}
+
 func htmlBlockSpan(block *ast.HTMLBlock, source []byte) (int, int, bool) {
 	lines := block.Lines()
 	if lines.Len() == 0 {
@@ -90,13 +174,20 @@ func applyHTMLReplacements(body string, replacements []htmlReplacement) string {
 }
 
 func sortHTMLReplacements(replacements []htmlReplacement) {
-	sort.Slice(replacements, func(i, j int) bool {
+	sort.SliceStable(replacements, func(i, j int) bool {
 		return replacements[i].Start < replacements[j].Start
 	})
 }
 
-func translateHTMLBlock(ctx context.Context, translator *PiTranslator, htmlText, srcLang, tgtLang string) (string, error) {
-	tokenizer := html.NewTokenizer(strings.NewReader(htmlText))
+// translateHTMLBlock translates one HTML block. JSX expressions are masked
+// first, and the result must have the same tags in the same order.
+func translateHTMLBlock(ctx context.Context, translator Translator, tm *TranslationMemory, relPath, htmlText, srcLang, tgtLang string, components componentAttrs) (string, error) {
+	state := NewPlaceholderState(htmlText)
+	placeholders := make([]string, 0, 4)
+	mapping := map[string]string{}
+	masked := maskJSXExpressions(htmlText, state.Next, &placeholders, mapping)
+
+	tokenizer := html.NewTokenizer(strings.NewReader(masked))
 	var out strings.Builder
 	skipDepth := 0
 
@@ -114,6 +205,13 @@ func translateHTMLBlock(ctx context.Context, translator *PiTranslator, htmlText,
 
 		switch tt {
 		case html.StartTagToken:
+			if skipDepth == 0 {
+				translated, err := components.translateTag(ctx, translator, tm, relPath, raw, srcLang, tgtLang)
+				if err != nil {
+					return "", err
+				}
+				raw = translated
+			}
 			out.WriteString(raw)
 			if isSkipTag(strings.ToLower(tok.Data)) {
 				skipDepth++
@@ -124,10 +222,17 @@ func translateHTMLBlock(ctx context.Context, translator *PiTranslator, htmlText,
 				skipDepth--
 			}
 		case html.SelfClosingTagToken:
+			if skipDepth == 0 {
+				translated, err := components.translateTag(ctx, translator, tm, relPath, raw, srcLang, tgtLang)
+				if err != nil {
+					return "", err
+				}
+				raw = translated
+			}
 			out.WriteString(raw)
 		case html.TextToken:
 			if shouldTranslateHTMLText(skipDepth, raw) {
-				translated, err := translator.Translate(ctx, raw, srcLang, tgtLang)
+				translated, err := translateSnippet(ctx, translator, tm, htmlSegmentID(relPath), raw, srcLang, tgtLang)
 				if err != nil {
 					return "", err
 				}
@@ -140,7 +245,14 @@ func translateHTMLBlock(ctx context.Context, translator *PiTranslator, htmlText,
 		}
 	}
 
-	return out.String(), nil
+	translated := out.String()
+	if err := validatePlaceholders(translated, placeholders); err != nil {
+		return "", err
+	}
+	if want, got := htmlTagSequence(masked), htmlTagSequence(translated); !slices.Equal(want, got) {
+		return "", fmt.Errorf("html block tags changed: %v, want %v", got, want)
+	}
+	return unmaskMarkdown(translated, placeholders, mapping), nil
 }
 
 func shouldTranslateHTMLText(skipDepth int, text string) bool {
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runJournal records a run so an interrupted one can be resumed: the
+// arguments it was started with, its job queue, the jobs finished so far and
+// the translation memory entries not yet saved to the memory files. Jobs are
+// named by their output path ("zh-CN/start/setup.md"). It is rewritten after
+// every job and when the run is interrupted, and removed once the run
+// completes.
+type runJournal struct {
+	path string
+	tms  map[string]*TranslationMemory
+
+	mu   sync.Mutex
+	data journalData
+}
+
+type journalData struct {
+	Args      []string `json:"args"`
+	StartedAt string   `json:"started_at"`
+	UpdatedAt string   `json:"updated_at"`
+	Queue     []string `json:"queue"`
+	Done      []string `json:"done"`
+	// TM holds the unsaved entries per target language.
+	TM map[string][]TMEntry `json:"tm,omitempty"`
+}
+
+// journalPath is the journal of a run over langs: docs/.i18n/zh-CN.run.json,
+// or zh-CN+ja-JP.run.json for several languages.
+func journalPath(docsRoot string, langs []string) string {
+	return filepath.Join(docsRoot, ".i18n", fmt.Sprintf("%s.run.json", strings.Join(langs, "+")))
+}
+
+// newRunJournal starts a journal for a run over queue (job keys), saving the
+// unsaved entries of tms by language.
+func newRunJournal(path string, tms map[string]*TranslationMemory, args, queue []string) *runJournal {
+	return &runJournal{path: path, tms: tms, data: journalData{
+		Args:      args,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Queue:     queue,
+		Done:      []string{},
+	}}
+}
+
+// loadRunJournal reads the journal of an interrupted run.
+func loadRunJournal(path string) (*runJournal, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no interrupted run to resume (%s not found)", path)
+		}
+		return nil, err
+	}
+	journal := &runJournal{path: path}
+	if err := json.Unmarshal(raw, &journal.data); err != nil {
+		return nil, fmt.Errorf("run journal decode failed: %w", err)
+	}
+	return journal, nil
+}
+
+// Remaining lists the queued jobs not finished yet, in queue order.
+func (j *runJournal) Remaining() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var remaining []string
+	for _, rel := range j.data.Queue {
+		if !slices.Contains(j.data.Done, rel) {
+			remaining = append(remaining, rel)
+		}
+	}
+	return remaining
+}
+
+// Restore puts the entries saved at the interruption back into the
+// translation memories of their languages, and journals the unsaved entries
+// of tms from now on.
+func (j *runJournal) Restore(tms map[string]*TranslationMemory) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tms = tms
+	for lang, entries := range j.data.TM {
+		tm, ok := tms[lang]
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			tm.Put(entry)
+		}
+	}
+}
+
+// MarkDone records a finished job and flushes the journal.
+func (j *runJournal) MarkDone(key string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	if !slices.Contains(j.data.Done, key) {
+		j.data.Done = append(j.data.Done, key)
+	}
+	j.mu.Unlock()
+	return j.Flush()
+}
+
+// Flush writes the journal with the translation memory entries that are not
+// in the memory file yet.
+func (j *runJournal) Flush() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.tms != nil {
+		j.data.TM = map[string][]TMEntry{}
+		for lang, tm := range j.tms {
+			if pending := tm.Pending(); len(pending) > 0 {
+				j.data.TM[lang] = pending
+			}
+		}
+	}
+	j.data.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(j.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, j.path)
+}
+
+// Remove deletes the journal of a completed run.
+func (j *runJournal) Remove() error {
+	if j == nil {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunJournalResume(t *testing.T) {
+	dir := t.TempDir()
+	tm, err := LoadTranslationMemory(filepath.Join(dir, "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := journalPath(dir, []string{"zh-CN"})
+	args := []string{"-lang", "zh-CN", "-mode", "doc"}
+	journal := newRunJournal(path, map[string]*TranslationMemory{"zh-CN": tm}, args, []string{"zh-CN/a.md", "zh-CN/b.md", "zh-CN/c.md"})
+	tm.Put(TMEntry{CacheKey: "k", Text: "Hello", Translated: "你好"})
+	if err := journal.MarkDone("zh-CN/b.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := loadRunJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.Remaining(); !slices.Equal(got, []string{"zh-CN/a.md", "zh-CN/c.md"}) {
+		t.Errorf("Remaining = %v", got)
+	}
+	if !slices.Equal(resumed.data.Args, args) {
+		t.Errorf("Args = %v", resumed.data.Args)
+	}
+
+	fresh, err := LoadTranslationMemory(filepath.Join(dir, "other.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Restore(map[string]*TranslationMemory{"zh-CN": fresh})
+	if entry, ok := fresh.Get("k"); !ok || entry.Translated != "你好" {
+		t.Errorf("restored entry = %+v, %v", entry, ok)
+	}
+
+	if err := resumed.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("journal still exists: %v", err)
+	}
+	if _, err := loadRunJournal(path); err == nil || !strings.Contains(err.Error(), "no interrupted run") {
+		t.Errorf("loadRunJournal after Remove = %v", err)
+	}
+}
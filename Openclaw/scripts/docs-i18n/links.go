@@ -0,0 +1,336 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+var (
+	// inlineLinkRe matches the destination of an inline link or image. The
+	// leading "!" is captured so images can be left alone.
+	inlineLinkRe  = regexp.MustCompile(`(!?)\[[^\]]*\]\(\s*([^)\s]+)`)
+	refLinkDefRe  = regexp.MustCompile(`^(\s{0,3}\[[^\]]+\]:\s*)(\S+)`)
+	hrefAttrRe    = regexp.MustCompile(`(\bhref=["'])([^"']+)`)
+	docExtensions = []string{".md", ".mdx"}
+)
+
+// linkLocalizer points links in translated docs at the translated pages. It
+// is safe for concurrent use; what it learns about the docs tree is cached.
+type linkLocalizer struct {
+	docsRoot string
+	lang     string
+	langDirs langDirs
+
+	mu      sync.Mutex
+	anchors map[string]map[string]string
+}
+
+func newLinkLocalizer(docsRoot, lang string, dirs langDirs) *linkLocalizer {
+	return &linkLocalizer{docsRoot: docsRoot, lang: lang, langDirs: dirs, anchors: map[string]map[string]string{}}
+}
+
+// Localize rewrites the doc links in translatedBody, the translation of
+// relPath (whose source body is sourceBody). Links to pages translated into
+// the target language move under /<lang>/ and their anchors follow the
+// translated headings; relative links to pages that aren't translated yet
+// fall back to the English page. Code and external links are left alone.
+func (l *linkLocalizer) Localize(relPath, sourceBody, translatedBody string) string {
+	if l == nil {
+		return translatedBody
+	}
+	relPath = filepath.ToSlash(relPath)
+	// The translation of relPath is being rewritten; what was cached from
+	// the old one is stale.
+	l.mu.Lock()
+	delete(l.anchors, relPath)
+	l.mu.Unlock()
+	self := pageAnchors(sourceBody, translatedBody)
+	rewrite := func(dest string) string {
+		return l.rewriteLink(relPath, self, dest)
+	}
+
+	lines := strings.SplitAfter(translatedBody, "\n")
+	fence := ""
+	for i, line := range lines {
+		next := updateFence(fence, line)
+		if fence == "" && next == "" {
+			lines[i] = rewriteLinkLine(line, rewrite)
+		}
+		fence = next
+	}
+	return strings.Join(lines, "")
+}
+
+// Relink localizes the links of the translation of relPath already on disk,
+// without a model, so links to pages translated after it was written follow
+// them. The machine output recorded in blocks follows the rewrite, so capture
+// doesn't take it for a human edit. It reports whether the file changed; a
+// page without a translation is left alone.
+func (l *linkLocalizer) Relink(blocks *BlockIndex, relPath string) (bool, error) {
+	if l == nil {
+		return false, nil
+	}
+	relPath = filepath.ToSlash(relPath)
+	outputPath := filepath.Join(l.docsRoot, l.lang, filepath.FromSlash(relPath))
+	translated, err := os.ReadFile(outputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	source, err := os.ReadFile(filepath.Join(l.docsRoot, filepath.FromSlash(relPath)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	_, sourceBody := splitFrontMatter(string(source))
+	_, translatedBody := splitFrontMatter(string(translated))
+	relinked := l.Localize(relPath, sourceBody, translatedBody)
+	if relinked == translatedBody {
+		return false, nil
+	}
+	if record, ok := blocks.Get(relPath); ok && record.Output != nil {
+		record.Output = localizedOutput(record.Output, translatedBody, relinked)
+		blocks.Put(record)
+	}
+	front := string(translated[:len(translated)-len(translatedBody)])
+	return true, os.WriteFile(outputPath, []byte(front+relinked), 0o644)
+}
+
+// relinkTranslations relinks every translation into lang under docsRoot. A
+// fresh localizer reads the headings of pages written earlier in the run. It
+// returns how many translations changed.
+func relinkTranslations(docsRoot, lang string, dirs langDirs, blocks *BlockIndex) (int, error) {
+	sources, err := listDocs(docsRoot, dirs)
+	if err != nil {
+		return 0, err
+	}
+	links := newLinkLocalizer(docsRoot, lang, dirs)
+	changed := 0
+	for _, rel := range sources {
+		ok, err := links.Relink(blocks, rel)
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// rewriteLinkLine rewrites the link destinations on one line outside code
+// spans.
+func rewriteLinkLine(line string, rewrite func(string) string) string {
+	if !strings.ContainsAny(line, "[h") {
+		return line
+	}
+	var out strings.Builder
+	pos := 0
+	for _, span := range inlineCodeRe.FindAllStringIndex(line, -1) {
+		out.WriteString(rewriteLinkText(line[pos:span[0]], rewrite))
+		out.WriteString(line[span[0]:span[1]])
+		pos = span[1]
+	}
+	out.WriteString(rewriteLinkText(line[pos:], rewrite))
+	return out.String()
+}
+
+func rewriteLinkText(text string, rewrite func(string) string) string {
+	text = replaceSubmatch(text, inlineLinkRe, 2, func(match []string) string {
+		if match[1] == "!" {
+			return match[2]
+		}
+		return rewrite(match[2])
+	})
+	text = replaceSubmatch(text, refLinkDefRe, 2, func(match []string) string {
+		return rewrite(match[2])
+	})
+	return replaceSubmatch(text, hrefAttrRe, 2, func(match []string) string {
+		return rewrite(match[2])
+	})
+}
+
+// replaceSubmatch replaces capture group group of every match of re.
+func replaceSubmatch(text string, re *regexp.Regexp, group int, replace func([]string) string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var out strings.Builder
+	pos := 0
+	for _, match := range matches {
+		groups := make([]string, len(match)/2)
+		for i := range groups {
+			if match[2*i] >= 0 {
+				groups[i] = text[match[2*i]:match[2*i+1]]
+			}
+		}
+		start, end := match[2*group], match[2*group+1]
+		out.WriteString(text[pos:start])
+		out.WriteString(replace(groups))
+		pos = end
+	}
+	out.WriteString(text[pos:])
+	return out.String()
+}
+
+func (l *linkLocalizer) rewriteLink(relPath string, self map[string]string, dest string) string {
+	if dest == "" || strings.Contains(dest, "://") || strings.HasPrefix(dest, "mailto:") {
+		return dest
+	}
+	target, anchor, hasAnchor := strings.Cut(dest, "#")
+	if target == "" {
+		return "#" + mapAnchor(self, anchor)
+	}
+	absolute := strings.HasPrefix(target, "/")
+	resolved := path.Clean(strings.TrimPrefix(target, "/"))
+	if !absolute {
+		resolved = path.Join(path.Dir(relPath), target)
+	}
+	if first, _, _ := strings.Cut(resolved, "/"); l.langDirs[first] || strings.HasPrefix(resolved, "../") {
+		// Already localized, or outside the docs tree.
+		return dest
+	}
+	page, ok := l.sourcePage(resolved)
+	if !ok {
+		// Not a doc (an asset, or a page that doesn't exist).
+		return dest
+	}
+
+	translated := l.translatedExists(page)
+	switch {
+	case translated && absolute:
+		target = "/" + l.lang + target
+	case !translated && !absolute:
+		target = "/" + resolved
+	}
+	if !hasAnchor {
+		return target
+	}
+	if translated {
+		anchors := self
+		if page != relPath {
+			anchors = l.pageAnchors(page)
+		}
+		anchor = mapAnchor(anchors, anchor)
+	}
+	return target + "#" + anchor
+}
+
+// sourcePage finds the source doc a link path refers to (with or without
+// extension, or a directory index) and returns its path under the docs root.
+func (l *linkLocalizer) sourcePage(linkPath string) (string, bool) {
+	base := strings.TrimSuffix(strings.TrimSuffix(linkPath, ".md"), ".mdx")
+	var candidates []string
+	for _, ext := range docExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range docExtensions {
+		candidates = append(candidates, path.Join(base, "index"+ext))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.Join(l.docsRoot, filepath.FromSlash(candidate))); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func (l *linkLocalizer) translatedExists(page string) bool {
+	_, err := os.Stat(filepath.Join(l.docsRoot, l.lang, filepath.FromSlash(page)))
+	return err == nil
+}
+
+// pageAnchors maps the source heading slugs of page to the slugs of its
+// translation on disk.
+func (l *linkLocalizer) pageAnchors(page string) map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if anchors, ok := l.anchors[page]; ok {
+		return anchors
+	}
+	var anchors map[string]string
+	source, errSource := os.ReadFile(filepath.Join(l.docsRoot, filepath.FromSlash(page)))
+	translated, errTranslated := os.ReadFile(filepath.Join(l.docsRoot, l.lang, filepath.FromSlash(page)))
+	if errSource == nil && errTranslated == nil {
+		_, sourceBody := splitFrontMatter(string(source))
+		_, translatedBody := splitFrontMatter(string(translated))
+		anchors = pageAnchors(sourceBody, translatedBody)
+	}
+	l.anchors[page] = anchors
+	return anchors
+}
+
+// pageAnchors pairs the headings of a source body and its translation by
+// position. It returns nil when the headings don't line up.
+func pageAnchors(sourceBody, translatedBody string) map[string]string {
+	source := headingSlugs(sourceBody)
+	translated := headingSlugs(translatedBody)
+	if len(source) == 0 || len(source) != len(translated) {
+		return nil
+	}
+	anchors := make(map[string]string, len(source))
+	for i, slug := range source {
+		if _, ok := anchors[slug]; !ok {
+			anchors[slug] = translated[i]
+		}
+	}
+	return anchors
+}
+
+func mapAnchor(anchors map[string]string, anchor string) string {
+	if mapped, ok := anchors[anchor]; ok {
+		return mapped
+	}
+	return anchor
+}
+
+// headingSlugs returns the anchor of every heading in body, in order.
+func headingSlugs(body string) []string {
+	source := []byte(body)
+	ids := newHeadingIDs()
+	var slugs []string
+	_ = ast.Walk(parseHeadings(source), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		id, _ := ids.next(heading, source)
+		slugs = append(slugs, id)
+		return ast.WalkSkipChildren, nil
+	})
+	return slugs
+}
+
+// localizedOutput updates machine output hashes for link rewriting: a block
+// that was still the machine output gets the hash of its rewritten text,
+// while a block a human edited keeps the recorded hash so capture sees the
+// edit. A nil output (everything fresh) stays nil.
+func localizedOutput(output []string, before, after string) []string {
+	if output == nil || before == after {
+		return output
+	}
+	beforeHashes := splitMarkdownBlocks(before).hashes()
+	afterHashes := splitMarkdownBlocks(after).hashes()
+	if len(beforeHashes) != len(output) || len(afterHashes) != len(output) {
+		return output
+	}
+	updated := make([]string, len(output))
+	for i, hash := range output {
+		updated[i] = hash
+		if hash == beforeHashes[i] {
+			updated[i] = afterHashes[i]
+		}
+	}
+	return updated
+}
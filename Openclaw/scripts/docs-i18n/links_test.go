@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLinkLocalizer(t *testing.T) {
+	docsRoot := t.TempDir()
+	for name, content := range map[string]string{
+		"start/intro.md":          "# Intro\n\n## Next steps\n",
+		"gateway/index.md":        "# Gateway\n\n## Remote access\n",
+		"gateway/security.md":     "# Security\n",
+		"zh-CN/gateway/index.md":  "---\ntitle: 网关\n---\n\n# 网关\n\n## 远程访问\n",
+		"assets/logo.png":         "png",
+		"start/not-translated.md": "# Later\n",
+	} {
+		path := filepath.Join(docsRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := "# Intro\n\n## Next steps\n"
+	translated := strings.Join([]string{
+		"# 介绍",
+		"",
+		"## 后续步骤",
+		"",
+		"见 [网关](/gateway#remote-access)、[安全](/gateway/security) 和 [稍后](./not-translated.md)。",
+		"跳到[后续步骤](#next-steps)，或访问 [GitHub](https://github.com/openclaw/openclaw)。",
+		"![标志](/assets/logo.png) `[code](/gateway)`",
+		`<Card title="网关" href="/gateway">`,
+		"",
+		"```md",
+		"[网关](/gateway)",
+		"```",
+		"",
+		"[gw]: ../gateway/index.md",
+		"",
+	}, "\n")
+	want := strings.Join([]string{
+		"# 介绍",
+		"",
+		"## 后续步骤",
+		"",
+		"见 [网关](/zh-CN/gateway#远程访问)、[安全](/gateway/security) 和 [稍后](/start/not-translated.md)。",
+		"跳到[后续步骤](#后续步骤)，或访问 [GitHub](https://github.com/openclaw/openclaw)。",
+		"![标志](/assets/logo.png) `[code](/gateway)`",
+		`<Card title="网关" href="/zh-CN/gateway">`,
+		"",
+		"```md",
+		"[网关](/gateway)",
+		"```",
+		"",
+		"[gw]: ../gateway/index.md",
+		"",
+	}, "\n")
+
+	links := newLinkLocalizer(docsRoot, "zh-CN", langDirs{"zh-CN": true})
+	got := links.Localize("start/intro.md", source, translated)
+	if got != want {
+		t.Fatalf("Localize:\n%s\nwant:\n%s", got, want)
+	}
+	if again := links.Localize("start/intro.md", source, got); again != got {
+		t.Fatalf("second pass changed links:\n%s", again)
+	}
+}
+
+func TestRelinkTranslationsFollowsLaterPages(t *testing.T) {
+	docsRoot := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(docsRoot, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := "# Intro\n\nSee [Security](../gateway/security.md#hardening).\n"
+	writeFile("start/intro.md", source)
+	writeFile("gateway/security.md", "# Security\n\n## Hardening\n")
+	// Translated while the security page was still English only.
+	body := "# 介绍\n\n见 [安全](/gateway/security.md#hardening)。\n"
+	writeFile("zh-CN/start/intro.md", "---\ntitle: 介绍\n---\n\n"+body)
+
+	blocks, err := LoadBlockIndex(filepath.Join(docsRoot, ".i18n", "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks.Put(BlockRecord{SourcePath: "start/intro.md", Blocks: splitMarkdownBlocks(source).hashes(), Output: splitMarkdownBlocks(body).hashes()})
+	dirs := langDirs{"zh-CN": true}
+	if changed, err := relinkTranslations(docsRoot, "zh-CN", dirs, blocks); err != nil || changed != 0 {
+		t.Fatalf("relink before the page was translated: %d, %v", changed, err)
+	}
+
+	writeFile("zh-CN/gateway/security.md", "# 安全\n\n## 加固\n")
+	if changed, err := relinkTranslations(docsRoot, "zh-CN", dirs, blocks); err != nil || changed != 1 {
+		t.Fatalf("relink after the page was translated: %d, %v", changed, err)
+	}
+	data, err := os.ReadFile(filepath.Join(docsRoot, "zh-CN", "start", "intro.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBody := "# 介绍\n\n见 [安全](/zh-CN/gateway/security.md#加固)。\n"
+	if string(data) != "---\ntitle: 介绍\n---\n\n"+wantBody {
+		t.Fatalf("relinked page:\n%s", data)
+	}
+	record, _ := blocks.Get("start/intro.md")
+	if !slices.Equal(record.Output, splitMarkdownBlocks(wantBody).hashes()) {
+		t.Error("recorded machine output does not follow the relink")
+	}
+	if changed, err := relinkTranslations(docsRoot, "zh-CN", dirs, blocks); err != nil || changed != 0 {
+		t.Fatalf("second relink changed %d pages, %v", changed, err)
+	}
+}
+
+func TestHeadingSlug(t *testing.T) {
+	for heading, want := range map[string]string{
+		"Remote access":              "remote-access",
+		"Config: `gateway.port` (1)": "config-gatewayport-1",
+		"Gateway 网关配置":               "gateway-网关配置",
+		"What's new?":                "whats-new",
+	} {
+		if got := headingSlug(heading); got != want {
+			t.Errorf("headingSlug(%q) = %q, want %q", heading, got, want)
+		}
+	}
+}
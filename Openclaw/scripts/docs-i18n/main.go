@@ -6,159 +6,558 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// docProcessor translates (or skips) a single doc. Every mode is adapted to
+// this shape so they all share the doc runners.
+type docProcessor func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error)
+
+// docJob translates one file into one target language. index is the file's
+// position in the language's queue.
 type docJob struct {
-	index int
-	path  string
-	rel   string
+	index  int
+	path   string
+	rel    string
+	target *langTarget
 }
 
 type docResult struct {
-	index    int
-	rel      string
+	job      docJob
 	duration time.Duration
 	skipped  bool
+	usage    tokenUsage
 	err      error
+	// worker is the parallel worker that ran the job; 0 when sequential.
+	worker int
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			clean, err := runCheck(os.Args[2:], os.Stdout)
+			if err != nil {
+				fatal(err)
+			}
+			if !clean {
+				os.Exit(1)
+			}
+			return
+		case "tm":
+			fatal(runTM(os.Args[2:], os.Stdout))
+			return
+		case "capture":
+			fatal(runCapture(os.Args[2:], os.Stdout))
+			return
+		case "nav":
+			fatal(runNav(os.Args[2:], os.Stdout))
+			return
+		}
+	}
+
 	var (
-		targetLang = flag.String("lang", "zh-CN", "target language (e.g., zh-CN)")
-		sourceLang = flag.String("src", "en", "source language")
-		docsRoot   = flag.String("docs", "docs", "docs root")
-		tmPath     = flag.String("tm", "", "translation memory path")
-		mode       = flag.String("mode", "segment", "translation mode (segment|doc)")
-		thinking   = flag.String("thinking", "high", "thinking level (low|high)")
-		overwrite  = flag.Bool("overwrite", false, "overwrite existing translations")
-		maxFiles   = flag.Int("max", 0, "max files to process (0 = all)")
-		parallel   = flag.Int("parallel", 1, "parallel workers for doc mode")
+		targetLang    = flag.String("lang", "zh-CN", "target languages, comma-separated (e.g., zh-CN or zh-CN,ja-JP)")
+		sourceLang    = flag.String("src", "en", "source language")
+		docsRoot      = flag.String("docs", "docs", "docs root")
+		tmPath        = flag.String("tm", "", "translation memory path (single -lang only)")
+		mode          = flag.String("mode", "segment", "translation mode (segment|doc|pseudo)")
+		thinking      = flag.String("thinking", "high", "thinking level (low|high)")
+		overwrite     = flag.Bool("overwrite", false, "overwrite existing translations")
+		maxFiles      = flag.Int("max", 0, "max files to process (0 = all)")
+		parallel      = flag.Int("parallel", 1, "parallel workers")
+		batchSize     = flag.Int("batch", defaultBatchSize, "max segments per request in segment mode (1 = no batching)")
+		fuzzy         = flag.Int("fuzzy", 3, "segment mode: similar translation memory entries sent as references per segment (0 = none)")
+		glossaryMode  = flag.String("glossary-check", "warn", "check translations against the glossary (off|warn|retry); retry retranslates offending segments or full docs once with corrections")
+		localizeLinks = flag.Bool("localize-links", true, "point doc links at translated pages and headings when they exist")
+		incremental   = flag.Bool("incremental", true, "doc mode: retranslate only changed blocks when possible")
+		chunkParallel = flag.Int("chunk-parallel", 1, "doc mode: chunks of one long doc translated at once (extra chunk translators are reused across docs)")
+		chunkTokens   = flag.Int("chunk-tokens", defaultChunkTokens, "doc mode: split docs above this many tokens at H2/H3 headings (0 = never)")
+		checkpoint    = flag.Duration("checkpoint", time.Minute, "translation memory checkpoint interval (0 = only save at the end)")
+		provider      = flag.String("provider", "pi", "translation backend (pi|openai|fake)")
+		endpoint      = flag.String("endpoint", "", "OpenAI-compatible API base URL (openai provider)")
+		model         = flag.String("model", "", "model name (default depends on provider)")
+		temperature   = flag.String("temperature", "", "openai provider: sampling temperature (default: the server's)")
+		reportPath    = flag.String("report", "", "write per-file token usage and cost to this JSON file")
+		budgetFlag    = flag.String("budget", "", "stop starting new files once this many tokens (e.g. 2M) or dollars (e.g. $20) are spent; both may be given, comma-separated")
+		resume        = flag.Bool("resume", false, "continue the interrupted run for -lang with its original options and remaining files")
+		since         = flag.String("since", "", "translate the source docs added, modified or renamed since this git ref instead of the file arguments")
+		all           = flag.Bool("all", false, "translate every source doc under the docs root instead of the file arguments")
+		deleted       = flag.String("deleted", "flag", "with -since, translations of deleted source docs: flag (log them) or delete")
+		codeComments  = flag.String("code-comments", "", "segment mode: also translate full-line comments in fenced code blocks with these info strings, comma-separated (e.g. bash,json5,yaml)")
+		eventsFormat  = flag.String("events", "", "also write machine-readable progress events in this format (jsonl)")
+		eventsPath    = flag.String("events-out", "-", "file to append -events to (- = stdout)")
+		qaRate        = flag.Float64("qa", 0, "back-translate this fraction of the translated segments (0 = off, 1 = all) and write a per-page QA report to docs/.i18n/<lang>.qa.json")
 	)
 	flag.Parse()
 	files := flag.Args()
-	if len(files) == 0 {
-		fatal(fmt.Errorf("no doc files provided"))
-	}
 
 	resolvedDocsRoot, err := filepath.Abs(*docsRoot)
 	if err != nil {
 		fatal(err)
 	}
-
-	if *tmPath == "" {
-		*tmPath = filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.tm.jsonl", *targetLang))
+	langs := splitList(*targetLang)
+	// An interrupted run left a journal; -resume continues it, otherwise a
+	// new run replaces it but keeps the translations it had not saved.
+	previous, err := loadRunJournal(journalPath(resolvedDocsRoot, langs))
+	switch {
+	case *resume && err != nil:
+		fatal(err)
+	case *resume:
+		if err := flag.CommandLine.Parse(previous.data.Args); err != nil {
+			fatal(err)
+		}
+		if resolvedDocsRoot, err = filepath.Abs(*docsRoot); err != nil {
+			fatal(err)
+		}
+		langs = splitList(*targetLang)
+		files = nil
+		log.Printf("docs-i18n: resuming the run started %s: %d of %d jobs left", previous.data.StartedAt, len(previous.Remaining()), len(previous.data.Queue))
+	case err == nil:
+		log.Printf("docs-i18n: replacing the interrupted run started %s (%d of %d jobs left); its unsaved translations are kept", previous.data.StartedAt, len(previous.Remaining()), len(previous.data.Queue))
+	default:
+		previous = nil
 	}
-
-	glossaryPath := filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("glossary.%s.json", *targetLang))
-	glossary, err := LoadGlossary(glossaryPath)
+	if len(langs) == 0 {
+		fatal(fmt.Errorf("no target language provided"))
+	}
+	if *tmPath != "" && len(langs) > 1 {
+		fatal(fmt.Errorf("-tm needs a single -lang"))
+	}
+	if *deleted != "flag" && *deleted != "delete" {
+		fatal(fmt.Errorf("unknown -deleted value: %s", *deleted))
+	}
+	commentPolicy, err := parseCodeCommentPolicy(splitList(*codeComments))
+	if err != nil {
+		fatal(fmt.Errorf("-code-comments: %w", err))
+	}
+	if len(commentPolicy) > 0 && *mode != "segment" {
+		fatal(fmt.Errorf("-code-comments needs segment mode"))
+	}
+	if *qaRate < 0 || *qaRate > 1 {
+		fatal(fmt.Errorf("-qa must be between 0 and 1"))
+	}
+	if *qaRate > 0 && *mode == "pseudo" {
+		fatal(fmt.Errorf("-qa needs a model; pseudo mode has none"))
+	}
+	dirs, err := languageDirs(resolvedDocsRoot, langs)
 	if err != nil {
 		fatal(err)
 	}
-
-	translator, err := NewPiTranslator(*sourceLang, *targetLang, glossary, *thinking)
+	events, err := openEventLog(*eventsFormat, *eventsPath)
 	if err != nil {
 		fatal(err)
 	}
-	defer translator.Close()
+	defer events.Close()
+	var changes []docChange
+	switch {
+	case *resume:
+	case (*since != "" || *all) && len(files) > 0:
+		fatal(fmt.Errorf("-since and -all replace the doc file arguments"))
+	case *since != "" && *all:
+		fatal(fmt.Errorf("-since and -all are mutually exclusive"))
+	case *all:
+		if files, err = allSourceFiles(resolvedDocsRoot, dirs); err != nil {
+			fatal(err)
+		}
+	case *since != "":
+		if changes, err = gitDocChanges(resolvedDocsRoot, *since, dirs); err != nil {
+			fatal(err)
+		}
+		if len(changes) == 0 {
+			log.Printf("docs-i18n: no source docs changed since %s", *since)
+			return
+		}
+		files = changedFiles(resolvedDocsRoot, changes)
+	}
+	if len(files) == 0 && !*resume && *since == "" {
+		fatal(fmt.Errorf("no doc files provided"))
+	}
+	budget, err := parseBudget(*budgetFlag)
+	if err != nil {
+		fatal(err)
+	}
+	if err := budget.checkProvider(*provider); err != nil {
+		fatal(err)
+	}
+	var samplingTemperature *float64
+	if *temperature != "" {
+		value, err := strconv.ParseFloat(*temperature, 64)
+		if err != nil || value < 0 {
+			fatal(fmt.Errorf("invalid -temperature: %s", *temperature))
+		}
+		samplingTemperature = &value
+	}
+	ledger := newUsageLedger(budget)
 
-	tm, err := LoadTranslationMemory(*tmPath)
+	components, err := LoadComponentAttrs(filepath.Join(resolvedDocsRoot, ".i18n", "components.json"))
 	if err != nil {
 		fatal(err)
 	}
 
+	translatorCfg := translatorConfig{
+		Provider:    *provider,
+		Endpoint:    *endpoint,
+		Model:       *model,
+		Thinking:    *thinking,
+		SrcLang:     *sourceLang,
+		Temperature: samplingTemperature,
+	}
+	if *mode == "pseudo" {
+		// Pseudo-localization never talks to a model.
+		translatorCfg.Provider = "fake"
+	}
+
+	targets := make([]*langTarget, 0, len(langs))
+	tms := map[string]*TranslationMemory{}
+	for _, lang := range langs {
+		target, err := loadLangTarget(resolvedDocsRoot, lang, *tmPath, *glossaryMode, translatorCfg)
+		if err != nil {
+			fatal(err)
+		}
+		if err := applyDocChanges(changes, resolvedDocsRoot, lang, target.TM, target.Blocks, target.Overrides, *deleted == "delete"); err != nil {
+			fatal(err)
+		}
+		targets = append(targets, target)
+		tms[lang] = target.TM
+	}
+	if previous != nil {
+		previous.Restore(tms)
+	}
+
 	ordered, err := orderFiles(resolvedDocsRoot, files)
 	if err != nil {
 		fatal(err)
 	}
 	totalFiles := len(ordered)
 	preSkipped := 0
-	if *mode == "doc" && !*overwrite {
-		filtered, skipped, err := filterDocQueue(resolvedDocsRoot, *targetLang, ordered)
-		if err != nil {
-			fatal(err)
+	if *resume {
+		// The journal names each remaining job by its output path.
+		byLang := map[string]*langTarget{}
+		for _, target := range targets {
+			byLang[target.Lang] = target
+		}
+		seen := map[string]bool{}
+		for _, key := range previous.Remaining() {
+			lang, rel, _ := strings.Cut(key, "/")
+			target, ok := byLang[lang]
+			if !ok {
+				continue
+			}
+			file := filepath.Join(resolvedDocsRoot, filepath.FromSlash(rel))
+			target.Queue = append(target.Queue, file)
+			if !seen[file] {
+				seen[file] = true
+				ordered = append(ordered, file)
+			}
+		}
+		totalFiles = len(ordered)
+	}
+	for _, target := range targets {
+		if *resume {
+			continue
+		}
+		target.Queue = ordered
+		if *mode == "doc" && !*overwrite {
+			filtered, skipped, err := filterDocQueue(resolvedDocsRoot, target.Lang, ordered)
+			if err != nil {
+				fatal(err)
+			}
+			target.Queue = filtered
+			preSkipped += skipped
+		}
+		if *maxFiles > 0 && *maxFiles < len(target.Queue) {
+			target.Queue = target.Queue[:*maxFiles]
+		}
+	}
+	jobs := buildJobs(resolvedDocsRoot, ordered, targets)
+	sources := newSourceCache(sourceUses(jobs))
+
+	for _, target := range targets {
+		var links *linkLocalizer
+		if *localizeLinks {
+			links = newLinkLocalizer(resolvedDocsRoot, target.Lang, dirs)
+		}
+		if *qaRate > 0 {
+			backCfg := target.Config
+			backCfg.SrcLang, backCfg.TgtLang = target.Lang, *sourceLang
+			backCfg.SystemPrompt = backTranslationPrompt(target.Lang, *sourceLang)
+			qaPath := filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.qa.json", target.Lang))
+			target.QA = newQAChecker(qaPath, target.Lang, *sourceLang, *qaRate, target.Config.Prompt, target.Config.Glossary, func() (Translator, error) {
+				return newTranslator(backCfg)
+			})
+			defer target.QA.Close()
+		}
+		switch *mode {
+		case "doc":
+			cfg := target.Config
+			spares := newSpareTranslators(func() (Translator, error) { return newTranslator(cfg) })
+			defer spares.Close()
+			target.Process = docModeProcessor(target.Blocks, target.Overrides, docOptions{
+				Incremental:   *incremental,
+				ChunkTokens:   *chunkTokens,
+				ChunkParallel: *chunkParallel,
+				Spares:        spares,
+				Glossary:      target.Check,
+				Links:         links,
+				Sources:       sources,
+				QA:            target.QA,
+				Components:    components,
+				TM:            target.TM,
+			})
+		case "pseudo":
+			target.Process = pseudoProcessor(components, sources)
+		case "segment":
+			target.Process = segmentProcessor(target.TM, target.Blocks, target.Overrides, segmentOptions{
+				BatchSize:    *batchSize,
+				References:   *fuzzy,
+				Glossary:     target.Check,
+				Links:        links,
+				Components:   components,
+				CodeComments: commentPolicy,
+				Sources:      sources,
+				QA:           target.QA,
+			})
+		default:
+			fatal(fmt.Errorf("unknown mode: %s", *mode))
+		}
+	}
+
+	translators := translatorPool{}
+	defer translators.Close()
+	translator, err := translators.get(targets[0])
+	if err != nil {
+		fatal(err)
+	}
+
+	journal := previous
+	if !*resume {
+		queue := make([]string, 0, len(jobs))
+		for _, job := range jobs {
+			queue = append(queue, jobKey(job.target.Lang, job.rel))
 		}
-		ordered = filtered
-		preSkipped = skipped
+		journal = newRunJournal(journalPath(resolvedDocsRoot, langs), tms, os.Args[1:], queue)
 	}
-	if *maxFiles > 0 && *maxFiles < len(ordered) {
-		ordered = ordered[:*maxFiles]
+	if err := journal.Flush(); err != nil {
+		fatal(err)
 	}
 
+	// The first interrupt stops the run after flushing the journal; a second
+	// one kills it.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-ctx.Done()
+		stopSignals()
+	}()
+
 	log.SetFlags(log.LstdFlags)
 	start := time.Now()
-	processed := 0
-	skipped := 0
 
 	if *parallel < 1 {
 		*parallel = 1
 	}
 
-	log.Printf("docs-i18n: mode=%s total=%d pending=%d pre_skipped=%d overwrite=%t thinking=%s parallel=%d", *mode, totalFiles, len(ordered), preSkipped, *overwrite, *thinking, *parallel)
-	switch *mode {
-	case "doc":
-		if *parallel > 1 {
-			proc, skip, err := runDocParallel(context.Background(), ordered, resolvedDocsRoot, *sourceLang, *targetLang, *overwrite, *parallel, glossary, *thinking)
-			if err != nil {
-				fatal(err)
+	saveSidecars := func(saveTM bool) error {
+		for _, target := range targets {
+			if saveTM {
+				if err := target.TM.Save(); err != nil {
+					return err
+				}
 			}
-			processed += proc
-			skipped += skip
-		} else {
-			proc, skip, err := runDocSequential(context.Background(), ordered, translator, resolvedDocsRoot, *sourceLang, *targetLang, *overwrite)
-			if err != nil {
-				fatal(err)
+			if err := target.Blocks.Save(); err != nil {
+				return err
+			}
+			if err := target.Overrides.Save(); err != nil {
+				return err
+			}
+			if err := target.QA.Save(resolvedDocsRoot); err != nil {
+				return err
 			}
-			processed += proc
-			skipped += skip
 		}
-	case "segment":
-		if *parallel > 1 {
-			fatal(fmt.Errorf("parallel processing is only supported in doc mode"))
+		return nil
+	}
+
+	var processed, skipped int
+	endRun := func(status string, err error) {
+		event := runEvent{
+			Event:      "run_end",
+			Status:     status,
+			Processed:  intPtr(processed),
+			Skipped:    intPtr(skipped),
+			PreSkipped: intPtr(preSkipped),
+			Remaining:  intPtr(len(jobs) - processed - skipped),
+			DurationMS: time.Since(start).Milliseconds(),
 		}
-		proc, err := runSegmentSequential(context.Background(), ordered, translator, tm, resolvedDocsRoot, *sourceLang, *targetLang)
+		usage := ledger.Total()
+		event.Usage = &usage
 		if err != nil {
-			fatal(err)
+			event.Error = err.Error()
 		}
-		processed += proc
-	default:
-		fatal(fmt.Errorf("unknown mode: %s", *mode))
+		events.Emit(event)
 	}
 
-	if err := tm.Save(); err != nil {
+	// failRun saves what the run produced before exiting; the journal stays
+	// so the run can be resumed. An interrupted run keeps its unsaved
+	// translations only in the journal.
+	failRun := func(err error) {
+		if flushErr := journal.Flush(); flushErr != nil {
+			log.Printf("docs-i18n: run journal flush failed: %v", flushErr)
+		}
+		if *mode != "pseudo" {
+			if saveErr := saveSidecars(ctx.Err() == nil); saveErr != nil {
+				log.Printf("docs-i18n: save failed: %v", saveErr)
+			}
+		}
+		if ctx.Err() != nil {
+			endRun("interrupted", ctx.Err())
+			events.Close()
+			log.Printf("docs-i18n: interrupted with %d jobs left; run again with -resume to continue", len(journal.Remaining()))
+			os.Exit(130)
+		}
+		endRun("failed", err)
+		events.Close()
+		log.Printf("docs-i18n: run again with -resume to continue after fixing the error")
 		fatal(err)
 	}
+
+	log.Printf("docs-i18n: mode=%s provider=%s model=%s langs=%s total=%d pending=%d pre_skipped=%d overwrite=%t thinking=%s parallel=%d", *mode, translator.Provider(), translator.Model(), strings.Join(langs, ","), totalFiles, len(jobs), preSkipped, *overwrite, *thinking, *parallel)
+	if len(targets) > 1 {
+		for _, target := range targets {
+			log.Printf("docs-i18n: [%s] pending=%d", target.Lang, len(target.Queue))
+		}
+	}
+	events.Emit(runEvent{
+		Event:      "run_start",
+		Mode:       *mode,
+		Provider:   translator.Provider(),
+		Model:      translator.Model(),
+		Langs:      langs,
+		Parallel:   *parallel,
+		Files:      totalFiles,
+		Jobs:       len(jobs),
+		PreSkipped: intPtr(preSkipped),
+	})
+	stopCheckpointing := func() {}
+	if *mode == "segment" {
+		stops := make([]func(), 0, len(targets))
+		for _, target := range targets {
+			stops = append(stops, target.TM.StartCheckpointing(*checkpoint))
+		}
+		stopCheckpointing = func() {
+			for _, stop := range stops {
+				stop()
+			}
+		}
+	}
+	if *parallel > 1 {
+		processed, skipped, err = runDocParallel(ctx, jobs, resolvedDocsRoot, *sourceLang, *overwrite, *parallel, ledger, journal, events)
+	} else {
+		processed, skipped, err = runDocSequential(ctx, jobs, translators, resolvedDocsRoot, *sourceLang, *overwrite, ledger, journal, events)
+	}
+	stopCheckpointing()
+	if err != nil {
+		failRun(err)
+	}
+	if ctx.Err() != nil {
+		// Interrupted between files.
+		failRun(ctx.Err())
+	}
+
+	if *localizeLinks && *mode != "pseudo" {
+		// Pages translated in this run may be linked from translations that
+		// weren't; point those links at them too.
+		for _, target := range targets {
+			relinked, err := relinkTranslations(resolvedDocsRoot, target.Lang, dirs, target.Blocks)
+			if err != nil {
+				fatal(err)
+			}
+			if relinked > 0 {
+				log.Printf("docs-i18n: [%s] relinked %d translated pages", target.Lang, relinked)
+			}
+		}
+	}
+	if *mode != "pseudo" {
+		if err := saveSidecars(true); err != nil {
+			fatal(err)
+		}
+	}
 	elapsed := time.Since(start).Round(time.Millisecond)
-	log.Printf("docs-i18n: completed processed=%d skipped=%d elapsed=%s", processed, skipped, elapsed)
+	remaining := len(jobs) - processed - skipped
+	status := "completed"
+	if ledger.Exhausted() && remaining > 0 {
+		status = "budget"
+		log.Printf("docs-i18n: budget of %s reached; %d jobs not started", budget, remaining)
+		if err := journal.Flush(); err != nil {
+			fatal(err)
+		}
+		log.Printf("docs-i18n: run again with -resume to continue")
+	} else if err := journal.Remove(); err != nil {
+		fatal(err)
+	}
+	log.Printf("docs-i18n: completed mode=%s processed=%d skipped=%d elapsed=%s %s", *mode, processed, skipped, elapsed, ledger.Total())
+	endRun(status, nil)
+	if *reportPath != "" {
+		report := ledger.Report(langs, *mode, translator, processed, skipped+preSkipped, remaining, elapsed)
+		if err := writeUsageReport(*reportPath, report); err != nil {
+			fatal(err)
+		}
+	}
 }
 
-func runDocSequential(ctx context.Context, ordered []string, translator *PiTranslator, docsRoot, srcLang, tgtLang string, overwrite bool) (int, int, error) {
+// runDocSequential runs the jobs in order until one fails or the ledger's
+// budget is spent.
+func runDocSequential(ctx context.Context, jobs []docJob, translators translatorPool, docsRoot, srcLang string, overwrite bool, ledger *usageLedger, journal *runJournal, events *eventLog) (int, int, error) {
 	processed := 0
 	skipped := 0
-	for index, file := range ordered {
-		relPath := resolveRelPath(docsRoot, file)
-		log.Printf("docs-i18n: [%d/%d] start %s", index+1, len(ordered), relPath)
+	for _, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return processed, skipped, err
+		}
+		if ledger.Exhausted() {
+			break
+		}
+		translator, err := translators.get(job.target)
+		if err != nil {
+			return processed, skipped, err
+		}
+		lang, total := job.target.Lang, len(job.target.Queue)
+		log.Printf("docs-i18n: [%s %d/%d] start %s", lang, job.index, total, job.rel)
+		events.FileStart(job, 0)
 		start := time.Now()
-		skip, err := processFileDoc(ctx, translator, docsRoot, file, srcLang, tgtLang, overwrite)
+		fileCtx, done := ledger.track(events.track(ctx, job, 0), lang, job.rel)
+		skip, err := job.target.Process(fileCtx, translator, docsRoot, job.path, srcLang, lang, overwrite)
+		usage := done(skip)
+		events.FileResult(docResult{job: job, duration: time.Since(start), skipped: skip, usage: usage, err: err})
 		if err != nil {
 			return processed, skipped, err
 		}
+		if err := journal.MarkDone(jobKey(lang, job.rel)); err != nil {
+			log.Printf("docs-i18n: run journal flush failed: %v", err)
+		}
 		if skip {
 			skipped++
-			log.Printf("docs-i18n: [%d/%d] skipped %s (%s)", index+1, len(ordered), relPath, time.Since(start).Round(time.Millisecond))
+			log.Printf("docs-i18n: [%s %d/%d] skipped %s (%s)", lang, job.index, total, job.rel, time.Since(start).Round(time.Millisecond))
 		} else {
 			processed++
-			log.Printf("docs-i18n: [%d/%d] done %s (%s)", index+1, len(ordered), relPath, time.Since(start).Round(time.Millisecond))
+			log.Printf("docs-i18n: [%s %d/%d] done %s (%s, %s)", lang, job.index, total, job.rel, time.Since(start).Round(time.Millisecond), usage.Brief())
 		}
 	}
 	return processed, skipped, nil
 }
 
-func runDocParallel(ctx context.Context, ordered []string, docsRoot, srcLang, tgtLang string, overwrite bool, parallel int, glossary []GlossaryEntry, thinking string) (int, int, error) {
-	jobs := make(chan docJob)
-	results := make(chan docResult, len(ordered))
+// runDocParallel runs the jobs with parallel workers, each starting its own
+// translator per language. Workers stop taking new jobs once the ledger's
+// budget is spent; jobs already started are finished.
+func runDocParallel(ctx context.Context, jobs []docJob, docsRoot, srcLang string, overwrite bool, parallel int, ledger *usageLedger, journal *runJournal, events *eventLog) (int, int, error) {
+	queue := make(chan docJob)
+	results := make(chan docResult, len(jobs)+parallel)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -167,25 +566,34 @@ func runDocParallel(ctx context.Context, ordered []string, docsRoot, srcLang, tg
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			translator, err := NewPiTranslator(srcLang, tgtLang, glossary, thinking)
-			if err != nil {
-				results <- docResult{err: err}
-				return
-			}
-			defer translator.Close()
-			for job := range jobs {
+			translators := translatorPool{}
+			defer translators.Close()
+			for job := range queue {
 				if ctx.Err() != nil {
 					return
 				}
-				log.Printf("docs-i18n: [w%d %d/%d] start %s", workerID, job.index, len(ordered), job.rel)
+				if ledger.Exhausted() {
+					continue
+				}
+				translator, err := translators.get(job.target)
+				if err != nil {
+					results <- docResult{job: job, err: err, worker: workerID}
+					cancel()
+					return
+				}
+				lang := job.target.Lang
+				log.Printf("docs-i18n: [w%d %s %d/%d] start %s", workerID, lang, job.index, len(job.target.Queue), job.rel)
+				events.FileStart(job, workerID)
 				start := time.Now()
-				skip, err := processFileDoc(ctx, translator, docsRoot, job.path, srcLang, tgtLang, overwrite)
+				fileCtx, done := ledger.track(events.track(ctx, job, workerID), lang, job.rel)
+				skip, err := job.target.Process(fileCtx, translator, docsRoot, job.path, srcLang, lang, overwrite)
 				results <- docResult{
-					index:    job.index,
-					rel:      job.rel,
+					job:      job,
 					duration: time.Since(start),
 					skipped:  skip,
+					usage:    done(skip),
 					err:      err,
+					worker:   workerID,
 				}
 				if err != nil {
 					cancel()
@@ -196,45 +604,53 @@ func runDocParallel(ctx context.Context, ordered []string, docsRoot, srcLang, tg
 	}
 
 	go func() {
-		for index, file := range ordered {
-			jobs <- docJob{index: index + 1, path: file, rel: resolveRelPath(docsRoot, file)}
+		defer close(queue)
+		for _, job := range jobs {
+			select {
+			case queue <- job:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(jobs)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
 	}()
 
 	processed := 0
 	skipped := 0
-	for i := 0; i < len(ordered); i++ {
-		result := <-results
+	var firstErr error
+	for result := range results {
+		events.FileResult(result)
 		if result.err != nil {
-			wg.Wait()
-			return processed, skipped, result.err
+			if firstErr == nil {
+				firstErr = result.err
+				cancel()
+			}
+			continue
+		}
+		job := result.job
+		if err := journal.MarkDone(jobKey(job.target.Lang, job.rel)); err != nil {
+			log.Printf("docs-i18n: run journal flush failed: %v", err)
 		}
 		if result.skipped {
 			skipped++
-			log.Printf("docs-i18n: [w* %d/%d] skipped %s (%s)", result.index, len(ordered), result.rel, result.duration.Round(time.Millisecond))
+			log.Printf("docs-i18n: [w* %s %d/%d] skipped %s (%s)", job.target.Lang, job.index, len(job.target.Queue), job.rel, result.duration.Round(time.Millisecond))
 		} else {
 			processed++
-			log.Printf("docs-i18n: [w* %d/%d] done %s (%s)", result.index, len(ordered), result.rel, result.duration.Round(time.Millisecond))
+			log.Printf("docs-i18n: [w* %s %d/%d] done %s (%s, %s)", job.target.Lang, job.index, len(job.target.Queue), job.rel, result.duration.Round(time.Millisecond), result.usage.Brief())
 		}
 	}
-	wg.Wait()
-	return processed, skipped, nil
+	return processed, skipped, firstErr
 }
 
-func runSegmentSequential(ctx context.Context, ordered []string, translator *PiTranslator, tm *TranslationMemory, docsRoot, srcLang, tgtLang string) (int, error) {
-	processed := 0
-	for index, file := range ordered {
-		relPath := resolveRelPath(docsRoot, file)
-		log.Printf("docs-i18n: [%d/%d] start %s", index+1, len(ordered), relPath)
-		start := time.Now()
-		if _, err := processFile(ctx, translator, tm, docsRoot, file, srcLang, tgtLang); err != nil {
-			return processed, err
-		}
-		processed++
-		log.Printf("docs-i18n: [%d/%d] done %s (%s)", index+1, len(ordered), relPath, time.Since(start).Round(time.Millisecond))
+// segmentProcessor adapts processFile to the doc runners. The translation
+// memory, block index and overrides are shared by all workers.
+func segmentProcessor(tm *TranslationMemory, blocks *BlockIndex, overrides *OverrideStore, opts segmentOptions) docProcessor {
+	return func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
+		return processFile(ctx, translator, tm, blocks, overrides, docsRoot, filePath, srcLang, tgtLang, opts)
 	}
-	return processed, nil
 }
 
 func resolveRelPath(docsRoot, file string) string {
@@ -4,26 +4,22 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/extension"
-	"github.com/yuin/goldmark/text"
 )
 
 func extractSegments(body, relPath string) ([]Segment, error) {
 	source := []byte(body)
-	r := text.NewReader(source)
-	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-	)
-	doc := md.Parser().Parse(r)
+	doc := parseHeadings(source)
 
 	segments := make([]Segment, 0, 128)
 	skipDepth := 0
 	var lastBlock ast.Node
+	// Translated headings keep the anchor of the source heading.
+	ids := newHeadingIDs()
+	var anchors []segmentAnchor
 
 	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-		switch n.(type) {
+		switch node := n.(type) {
 		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.CodeSpan, *ast.HTMLBlock, *ast.RawHTML:
 			if entering {
 				skipDepth++
@@ -31,6 +27,13 @@ func extractSegments(body, relPath string) ([]Segment, error) {
 				skipDepth--
 			}
 			return ast.WalkContinue, nil
+		case *ast.Heading:
+			if entering {
+				id, explicit := ids.next(node, source)
+				if start, stop, ok := headingContent(node); ok && !explicit {
+					anchors = append(anchors, segmentAnchor{Start: start, Stop: stop, ID: id})
+				}
+			}
 		}
 
 		if !entering || skipDepth > 0 {
@@ -90,10 +93,35 @@ func extractSegments(body, relPath string) ([]Segment, error) {
 	sort.Slice(filtered, func(i, j int) bool {
 		return filtered[i].Start < filtered[j].Start
 	})
+	attachAnchors(filtered, anchors)
 
 	return filtered, nil
 }
 
+// segmentAnchor is the source anchor of a heading whose text spans
+// [Start, Stop).
+type segmentAnchor struct {
+	Start int
+	Stop  int
+	ID    string
+}
+
+// attachAnchors hangs each heading anchor on the first segment of the
+// heading, so applyTranslations emits it at the start of the heading text.
+// Headings without translatable text keep their slug and need none.
+func attachAnchors(segments []Segment, anchors []segmentAnchor) {
+	i := 0
+	for _, anchor := range anchors {
+		for i < len(segments) && segments[i].Start < anchor.Start {
+			i++
+		}
+		if i < len(segments) && segments[i].Stop <= anchor.Stop {
+			segments[i].Anchor = anchor.ID
+			segments[i].AnchorAt = anchor.Start
+		}
+	}
+}
+
 func blockParent(n ast.Node) ast.Node {
 	for node := n.Parent(); node != nil; node = node.Parent() {
 		if isTranslatableBlock(node) {
@@ -113,19 +141,25 @@ func isTranslatableBlock(n ast.Node) bool {
 }
 
 func applyTranslations(body string, segments []Segment) string {
-	if len(segments) == 0 {
-		return body
-	}
-	var out strings.Builder
-	last := 0
+	return applyHTMLReplacements(body, segmentReplacements(segments))
+}
+
+// segmentReplacements turns translated segments, and the anchors of the
+// headings they start, into replacements of the body they were extracted
+// from.
+func segmentReplacements(segments []Segment) []htmlReplacement {
+	replacements := make([]htmlReplacement, 0, len(segments))
 	for _, seg := range segments {
-		if seg.Start < last {
-			continue
+		value := seg.Translated
+		if seg.Comment {
+			value = strings.Join(strings.Fields(value), " ")
+		}
+		if seg.Anchor != "" {
+			// Ahead of the segment, which may start at the same offset; the
+			// sort is stable.
+			replacements = append(replacements, htmlReplacement{Start: seg.AnchorAt, Stop: seg.AnchorAt, Value: headingAnchor(seg.Anchor)})
 		}
-		out.WriteString(body[last:seg.Start])
-		out.WriteString(seg.Translated)
-		last = seg.Stop
+		replacements = append(replacements, htmlReplacement{Start: seg.Start, Stop: seg.Stop, Value: value})
 	}
-	out.WriteString(body[last:])
-	return out.String()
+	return replacements
 }
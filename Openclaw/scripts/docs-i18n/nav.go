@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	navConfigName = "docs.json"
+	// navLineWidth is the print width of the repo formatter (oxfmt), which
+	// fits short arrays in docs.json on one line.
+	navLineWidth = 100
+)
+
+// navLabelKeys are the navigation fields shown to readers.
+var navLabelKeys = map[string]bool{"tab": true, "group": true, "anchor": true, "dropdown": true, "label": true, "description": true}
+
+// mintlifyLanguages maps docs language directories to Mintlify language
+// codes where they differ from the primary subtag.
+var mintlifyLanguages = map[string]string{
+	"zh-CN": "zh-Hans",
+	"zh-TW": "zh-Hant",
+	"pt-BR": "pt-BR",
+}
+
+func mintlifyLanguage(lang string) string {
+	if code, ok := mintlifyLanguages[lang]; ok {
+		return code
+	}
+	primary, _, _ := strings.Cut(lang, "-")
+	return strings.ToLower(primary)
+}
+
+// jsonValue is a JSON value that keeps object keys in document order.
+type jsonValue struct {
+	kind    json.Delim // '{', '[' or 0 for scalars
+	members []jsonMember
+	items   []*jsonValue
+	scalar  any
+}
+
+type jsonMember struct {
+	key   string
+	value *jsonValue
+}
+
+func parseJSONValue(data []byte) (*jsonValue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeJSONValue(decoder)
+}
+
+func decodeJSONValue(decoder *json.Decoder) (*jsonValue, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return &jsonValue{scalar: token}, nil
+	}
+	value := &jsonValue{kind: delim}
+	for decoder.More() {
+		if delim == '{' {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			member, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			value.members = append(value.members, jsonMember{key: key.(string), value: member})
+			continue
+		}
+		item, err := decodeJSONValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		value.items = append(value.items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// formatJSONValue renders value the way the docs formatter does: objects
+// expanded, arrays of scalars on one line when they fit. column is where the
+// value starts on its line.
+func formatJSONValue(value *jsonValue, indent string, column int) string {
+	switch value.kind {
+	case '{':
+		if len(value.members) == 0 {
+			return "{}"
+		}
+		var out strings.Builder
+		out.WriteString("{\n")
+		inner := indent + "  "
+		for i, member := range value.members {
+			prefix := inner + encodeJSONScalar(member.key) + ": "
+			out.WriteString(prefix)
+			out.WriteString(formatJSONValue(member.value, inner, len(prefix)))
+			if i < len(value.members)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent + "}")
+		return out.String()
+	case '[':
+		if len(value.items) == 0 {
+			return "[]"
+		}
+		scalars := make([]string, 0, len(value.items))
+		for _, item := range value.items {
+			if item.kind != 0 {
+				scalars = nil
+				break
+			}
+			scalars = append(scalars, encodeJSONScalar(item.scalar))
+		}
+		if scalars != nil {
+			// Leave room for a trailing comma.
+			if inline := "[" + strings.Join(scalars, ", ") + "]"; column+len(inline)+1 <= navLineWidth {
+				return inline
+			}
+		}
+		var out strings.Builder
+		out.WriteString("[\n")
+		inner := indent + "  "
+		for i, item := range value.items {
+			out.WriteString(inner)
+			out.WriteString(formatJSONValue(item, inner, len(inner)))
+			if i < len(value.items)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent + "]")
+		return out.String()
+	default:
+		return encodeJSONScalar(value.scalar)
+	}
+}
+
+func encodeJSONScalar(value any) string {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "null"
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// localizeNav copies a language's navigation for another one: labels go
+// through label, page paths are moved under lang and reported to page.
+func localizeNav(value *jsonValue, lang, code string, label func(string) string, page func(string)) *jsonValue {
+	switch value.kind {
+	case '{':
+		out := &jsonValue{kind: '{'}
+		for _, member := range value.members {
+			text, isString := member.value.scalar.(string)
+			localized := member.value
+			switch {
+			case member.key == "language" && isString:
+				localized = &jsonValue{scalar: code}
+			case navLabelKeys[member.key] && isString:
+				localized = &jsonValue{scalar: label(text)}
+			case member.key == "root" && isString:
+				localized = &jsonValue{scalar: localizePage(text, lang, page)}
+			case member.key == "pages" && member.value.kind == '[':
+				pages := &jsonValue{kind: '['}
+				for _, item := range member.value.items {
+					if path, ok := item.scalar.(string); ok {
+						pages.items = append(pages.items, &jsonValue{scalar: localizePage(path, lang, page)})
+						continue
+					}
+					pages.items = append(pages.items, localizeNav(item, lang, code, label, page))
+				}
+				localized = pages
+			case member.value.kind != 0:
+				localized = localizeNav(member.value, lang, code, label, page)
+			}
+			out.members = append(out.members, jsonMember{key: member.key, value: localized})
+		}
+		return out
+	case '[':
+		out := &jsonValue{kind: '['}
+		for _, item := range value.items {
+			out.items = append(out.items, localizeNav(item, lang, code, label, page))
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+func localizePage(path, lang string, page func(string)) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	page(path)
+	return lang + "/" + strings.TrimPrefix(path, "/")
+}
+
+func navSegmentID(label string) string {
+	return segmentID(navConfigName, hashText(label))
+}
+
+// navLanguages returns the raw navigation.languages entries of docs.json,
+// keyed by Mintlify language code, in document order.
+func navLanguages(data []byte) ([]string, map[string]json.RawMessage, error) {
+	var config struct {
+		Navigation struct {
+			Languages []json.RawMessage `json:"languages"`
+		} `json:"navigation"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("%s parse failed: %w", navConfigName, err)
+	}
+	var codes []string
+	entries := map[string]json.RawMessage{}
+	for _, raw := range config.Navigation.Languages {
+		var entry struct {
+			Language string `json:"language"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, entry.Language)
+		entries[entry.Language] = raw
+	}
+	return codes, entries, nil
+}
+
+// sourceNavLabels returns the navigation labels of the source language in
+// docs.json, or none when there is no docs.json.
+func sourceNavLabels(docsRoot, srcLang string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(docsRoot, navConfigName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	_, entries, err := navLanguages(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := entries[mintlifyLanguage(srcLang)]
+	if !ok {
+		return nil, nil
+	}
+	source, err := parseJSONValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	localizeNav(source, "", "", func(text string) string {
+		labels = append(labels, text)
+		return text
+	}, func(string) {})
+	return labels, nil
+}
+
+type navResult struct {
+	Code       string
+	Labels     int
+	Translated int
+}
+
+// localizeNavConfig returns docs.json with the navigation for tgtLang
+// regenerated from the source language's: labels translated through the
+// translation memory and pages pointing at <tgtLang>/. Only that language's
+// entry changes. It fails when a localized page doesn't exist.
+func localizeNavConfig(ctx context.Context, translator Translator, tm *TranslationMemory, docsRoot string, data []byte, srcLang, tgtLang, code string, batchSize int) ([]byte, navResult, error) {
+	result := navResult{Code: code}
+	codes, entries, err := navLanguages(data)
+	if err != nil {
+		return nil, result, err
+	}
+	sourceRaw, ok := entries[mintlifyLanguage(srcLang)]
+	if !ok {
+		return nil, result, fmt.Errorf("%s has no %q navigation", navConfigName, mintlifyLanguage(srcLang))
+	}
+	source, err := parseJSONValue(sourceRaw)
+	if err != nil {
+		return nil, result, err
+	}
+
+	var labels []string
+	seen := map[string]bool{}
+	var missing []string
+	localizeNav(source, tgtLang, code, func(text string) string {
+		if strings.TrimSpace(text) != "" && !seen[text] {
+			seen[text] = true
+			labels = append(labels, text)
+		}
+		return text
+	}, func(path string) {
+		if !localizedPageExists(docsRoot, tgtLang, path) {
+			missing = append(missing, tgtLang+"/"+path)
+		}
+	})
+	if len(missing) > 0 {
+		return nil, result, fmt.Errorf("%d localized page(s) in the navigation are missing: %s", len(missing), strings.Join(missing, ", "))
+	}
+
+	// Labels already in the localized navigation were reviewed on the live
+	// site; keep them unless the translation memory has its own.
+	existing := map[string]string{}
+	if targetRaw, ok := entries[code]; ok {
+		if target, err := parseJSONValue(targetRaw); err == nil {
+			pairNavLabels(source, target, tgtLang+"/", existing)
+		}
+	}
+	namespace := cacheNamespace(translator.Provider(), translator.Model(), translator.Profile())
+	segments := make([]Segment, 0, len(labels))
+	var kept []*Segment
+	for _, text := range labels {
+		seg := Segment{Text: text, TextHash: hashText(text), SegmentID: navSegmentID(text)}
+		seg.CacheKey = cacheKey(namespace, srcLang, tgtLang, seg.SegmentID, seg.TextHash)
+		if _, cached := tm.Get(seg.CacheKey); !cached && existing[text] != "" {
+			seg.Translated = existing[text]
+			kept = append(kept, &seg)
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	pending, reused := lookupSegments(tm, namespace, segments, srcLang, tgtLang, 0)
+	reused = append(reused, kept...)
+	if err := translateSegments(ctx, translator, pending, srcLang, tgtLang, segmentOptions{BatchSize: batchSize}); err != nil {
+		return nil, result, fmt.Errorf("translate failed (%s): %w", navConfigName, err)
+	}
+	storeSegments(tm, translator, navConfigName, append(pending, reused...), srcLang, tgtLang)
+	translated := map[string]string{}
+	for _, seg := range segments {
+		translated[seg.Text] = strings.TrimSpace(seg.Translated)
+	}
+	for _, seg := range kept {
+		translated[seg.Text] = seg.Translated
+	}
+	result.Labels = len(labels)
+	result.Translated = len(pending)
+
+	localized := localizeNav(source, tgtLang, code, func(text string) string {
+		if value, ok := translated[text]; ok && value != "" {
+			return value
+		}
+		return text
+	}, func(string) {})
+
+	// Splice the entry into the original text so the rest of the file keeps
+	// its formatting.
+	start := bytes.Index(data, sourceRaw)
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	indent := string(data[lineStart:start])
+	rendered := []byte(formatJSONValue(localized, indent, len(indent)))
+	if targetRaw, ok := entries[code]; ok {
+		at := bytes.Index(data, targetRaw)
+		return concatBytes(data[:at], rendered, data[at+len(targetRaw):]), result, nil
+	}
+	last := entries[codes[len(codes)-1]]
+	end := bytes.Index(data, last) + len(last)
+	return concatBytes(data[:end], []byte(",\n"+indent), rendered, data[end:]), result, nil
+}
+
+// pairNavLabels records the label the localized navigation uses for each
+// source label at the same place. Array items are matched by the first page
+// they contain, so added or removed groups don't shift the pairing.
+func pairNavLabels(source, target *jsonValue, prefix string, labels map[string]string) {
+	switch source.kind {
+	case '{':
+		for _, member := range source.members {
+			other := target.member(member.key)
+			if other == nil {
+				continue
+			}
+			text, ok := member.value.scalar.(string)
+			translated, okTarget := other.scalar.(string)
+			switch {
+			case navLabelKeys[member.key] && ok && okTarget:
+				if _, seen := labels[text]; !seen && strings.TrimSpace(translated) != "" {
+					labels[text] = translated
+				}
+			case member.value.kind != 0 && member.value.kind == other.kind:
+				pairNavLabels(member.value, other, prefix, labels)
+			}
+		}
+	case '[':
+		byPage := map[string]*jsonValue{}
+		for _, item := range target.items {
+			if page := firstNavPage(item); page != "" {
+				byPage[strings.TrimPrefix(page, prefix)] = item
+			}
+		}
+		for _, item := range source.items {
+			if other := byPage[firstNavPage(item)]; other != nil && other.kind == item.kind {
+				pairNavLabels(item, other, prefix, labels)
+			}
+		}
+	}
+}
+
+func (value *jsonValue) member(key string) *jsonValue {
+	for _, member := range value.members {
+		if member.key == key {
+			return member.value
+		}
+	}
+	return nil
+}
+
+func firstNavPage(value *jsonValue) string {
+	switch value.kind {
+	case '{':
+		if pages := value.member("pages"); pages != nil {
+			for _, item := range pages.items {
+				if page, ok := item.scalar.(string); ok {
+					return page
+				}
+				if page := firstNavPage(item); page != "" {
+					return page
+				}
+			}
+		}
+		for _, member := range value.members {
+			if page := firstNavPage(member.value); page != "" {
+				return page
+			}
+		}
+	case '[':
+		for _, item := range value.items {
+			if page := firstNavPage(item); page != "" {
+				return page
+			}
+		}
+	}
+	return ""
+}
+
+func localizedPageExists(docsRoot, lang, page string) bool {
+	for _, ext := range []string{".md", ".mdx"} {
+		if _, err := os.Stat(filepath.Join(docsRoot, lang, filepath.FromSlash(page)+ext)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var out bytes.Buffer
+	for _, part := range parts {
+		out.Write(part)
+	}
+	return out.Bytes()
+}
+
+// runNav implements `docs-i18n nav`: regenerate a language's navigation in
+// docs.json from the source language's.
+func runNav(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("nav", flag.ContinueOnError)
+	var (
+		targetLang = flags.String("lang", "zh-CN", "target language (e.g., zh-CN)")
+		sourceLang = flags.String("src", "en", "source language")
+		docsRoot   = flags.String("docs", "docs", "docs root")
+		tmPath     = flags.String("tm", "", "translation memory path")
+		navLang    = flags.String("nav-lang", "", "Mintlify language code (default derived from -lang, e.g. zh-CN -> zh-Hans)")
+		thinking   = flags.String("thinking", "high", "thinking level (low|high)")
+		batchSize  = flags.Int("batch", defaultBatchSize, "max labels per request (1 = no batching)")
+		provider   = flags.String("provider", "pi", "translation backend (pi|openai|fake)")
+		endpoint   = flags.String("endpoint", "", "OpenAI-compatible API base URL (openai provider)")
+		model      = flags.String("model", "", "model name (default depends on provider)")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	resolvedDocsRoot, err := filepath.Abs(*docsRoot)
+	if err != nil {
+		return err
+	}
+	if *tmPath == "" {
+		*tmPath = filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.tm.jsonl", *targetLang))
+	}
+	if *navLang == "" {
+		*navLang = mintlifyLanguage(*targetLang)
+	}
+	configPath := filepath.Join(resolvedDocsRoot, navConfigName)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	glossary, err := LoadGlossary(filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("glossary.%s.json", *targetLang)))
+	if err != nil {
+		return err
+	}
+	profile, err := LoadPromptProfile(filepath.Join(resolvedDocsRoot, ".i18n", "prompts", fmt.Sprintf("%s.yaml", *targetLang)), *targetLang)
+	if err != nil {
+		return err
+	}
+	translator, err := newTranslator(translatorConfig{
+		Provider: *provider,
+		Endpoint: *endpoint,
+		Model:    *model,
+		Thinking: *thinking,
+		SrcLang:  *sourceLang,
+		TgtLang:  *targetLang,
+		Glossary: glossary,
+		Prompt:   profile,
+	})
+	if err != nil {
+		return err
+	}
+	defer translator.Close()
+	tm, err := LoadTranslationMemory(*tmPath)
+	if err != nil {
+		return err
+	}
+
+	updated, result, err := localizeNavConfig(context.Background(), translator, tm, resolvedDocsRoot, data, *sourceLang, *targetLang, *navLang, *batchSize)
+	if err != nil {
+		return err
+	}
+	if err := tm.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %s navigation with %d labels (%d newly translated)\n", navConfigName, result.Code, result.Labels, result.Translated)
+	if bytes.Equal(updated, data) {
+		return nil
+	}
+	return os.WriteFile(configPath, updated, 0o644)
+}
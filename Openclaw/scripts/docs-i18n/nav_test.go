@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const navFixture = `{
+  "name": "OpenClaw",
+  "navbar": { "links": [{ "label": "GitHub", "href": "https://github.com/openclaw/openclaw" }] },
+  "navigation": {
+    "languages": [
+      {
+        "language": "en",
+        "tabs": [
+          {
+            "tab": "Get started",
+            "groups": [
+              { "group": "Home", "pages": ["index"] },
+              { "group": "First steps", "pages": ["start/getting-started", "start/wizard"] }
+            ]
+          }
+        ]
+      },
+      {
+        "language": "zh-Hans",
+        "tabs": [
+          {
+            "tab": "快速开始",
+            "groups": [{ "group": "首页", "pages": ["zh-CN/index"] }]
+          }
+        ]
+      }
+    ]
+  }
+}
+`
+
+func TestLocalizeNavConfig(t *testing.T) {
+	docsRoot := t.TempDir()
+	for _, page := range []string{"zh-CN/index.md", "zh-CN/start/getting-started.md", "zh-CN/start/wizard.md"} {
+		path := filepath.Join(docsRoot, filepath.FromSlash(page))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# Page\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tm, err := LoadTranslationMemory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(navFixture)
+	updated, result, err := localizeNavConfig(context.Background(), NewFakeTranslator(), tm, docsRoot, data, "en", "zh-CN", "zh-Hans", defaultBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Labels != 3 || result.Translated != 1 {
+		t.Errorf("result = %+v, want 3 labels with only the new group translated", result)
+	}
+	output := string(updated)
+	for _, want := range []string{
+		`"tab": "快速开始"`,
+		`"group": "首页"`,
+		`"group": "` + pseudoLocalize("First steps") + `"`,
+		`"pages": ["zh-CN/start/getting-started", "zh-CN/start/wizard"]`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output is missing %s:\n%s", want, output)
+		}
+	}
+	enEnd := strings.Index(navFixture, `      {
+        "language": "zh-Hans"`)
+	if !strings.HasPrefix(output, navFixture[:enEnd]) || !strings.HasSuffix(output, "    ]\n  }\n}\n") {
+		t.Errorf("text outside the zh-Hans entry changed:\n%s", output)
+	}
+	if !json.Valid(updated) {
+		t.Fatalf("output is not valid JSON:\n%s", output)
+	}
+
+	again, _, err := localizeNavConfig(context.Background(), NewFakeTranslator(), tm, docsRoot, updated, "en", "zh-CN", "zh-Hans", defaultBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != output {
+		t.Errorf("second run changed docs.json:\n%s", again)
+	}
+
+	_, _, err = localizeNavConfig(context.Background(), NewFakeTranslator(), tm, docsRoot, data, "en", "ja-JP", "ja", defaultBatchSize)
+	if err == nil || !strings.Contains(err.Error(), "ja-JP/index") {
+		t.Fatalf("err = %v, want the missing ja-JP pages", err)
+	}
+}
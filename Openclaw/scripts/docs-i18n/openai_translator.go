@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOpenAIEndpoint = "http://127.0.0.1:8080/v1"
+	openAIRequestTimeout  = 10 * time.Minute
+)
+
+var (
+	errUpstreamUnavailable = errors.New("upstream unavailable")
+	// errOutputTruncated means the reply hit the model's output limit.
+	errOutputTruncated = errors.New("output truncated")
+)
+
+// OpenAITranslator talks to any server exposing an OpenAI-compatible
+// /v1/chat/completions endpoint (llama.cpp, vLLM, OpenAI itself).
+type OpenAITranslator struct {
+	client       *http.Client
+	url          string
+	model        string
+	apiKey       string
+	systemPrompt string
+	profile      string
+	// temperature is sent when set; nil keeps the server's default.
+	temperature *float64
+}
+
+func NewOpenAITranslator(systemPrompt, profile, endpoint, model string, temperature *float64) (*OpenAITranslator, error) {
+	if strings.TrimSpace(model) == "" {
+		return nil, errors.New("openai provider requires -model")
+	}
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = defaultOpenAIEndpoint
+	}
+	return &OpenAITranslator{
+		client:       &http.Client{Timeout: openAIRequestTimeout},
+		url:          chatCompletionsURL(endpoint),
+		model:        strings.TrimSpace(model),
+		apiKey:       strings.TrimSpace(os.Getenv("OPENAI_API_KEY")),
+		systemPrompt: systemPrompt,
+		profile:      profile,
+		temperature:  temperature,
+	}, nil
+}
+
+func chatCompletionsURL(endpoint string) string {
+	trimmed := strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if strings.HasSuffix(trimmed, "/chat/completions") {
+		return trimmed
+	}
+	return trimmed + "/chat/completions"
+}
+
+func (t *OpenAITranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateMasked(ctx, t.prompt, hints, core)
+	})
+}
+
+func (t *OpenAITranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateRaw(ctx, t.prompt, hints, core)
+	})
+}
+
+func (t *OpenAITranslator) Provider() string {
+	return "openai"
+}
+
+func (t *OpenAITranslator) Model() string {
+	return t.model
+}
+
+func (t *OpenAITranslator) Profile() string {
+	return t.profile
+}
+
+func (t *OpenAITranslator) Close() {
+	t.client.CloseIdleConnections()
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	Stream      bool          `json:"stream"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatCompletionUsage `json:"usage,omitempty"`
+	Error *chatCompletionError `json:"error,omitempty"`
+}
+
+type chatCompletionUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// tokenUsage converts the response usage. The API reports no cost.
+func (u *chatCompletionUsage) tokenUsage() tokenUsage {
+	if u == nil {
+		return tokenUsage{Calls: 1}
+	}
+	cached := 0
+	if u.PromptTokensDetails != nil {
+		cached = u.PromptTokensDetails.CachedTokens
+	}
+	return tokenUsage{Calls: 1, Input: u.PromptTokens - cached, Output: u.CompletionTokens, CacheRead: cached}
+}
+
+type chatCompletionError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+func (t *OpenAITranslator) prompt(ctx context.Context, message string) (string, error) {
+	payload, err := json.Marshal(chatCompletionRequest{
+		Model: t.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: t.systemPrompt},
+			{Role: "user", Content: message},
+		},
+		Temperature: t.temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("openai request failed: %w: %v", errUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded chatCompletionResponse
+	decodeErr := json.Unmarshal(body, &decoded)
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(body))
+		if decodeErr == nil && decoded.Error != nil && decoded.Error.Message != "" {
+			msg = decoded.Error.Message
+		}
+		if resp.StatusCode >= 500 {
+			return "", fmt.Errorf("openai error: status %d: %w: %s", resp.StatusCode, errUpstreamUnavailable, msg)
+		}
+		return "", fmt.Errorf("openai error: status %d: %s", resp.StatusCode, msg)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("openai response decode failed: %w", decodeErr)
+	}
+	recordUsage(ctx, decoded.Usage.tokenUsage())
+	if decoded.Error != nil && decoded.Error.Message != "" {
+		return "", fmt.Errorf("openai error: %s", decoded.Error.Message)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", errors.New("openai response has no choices")
+	}
+	choice := decoded.Choices[0]
+	if choice.FinishReason == "length" {
+		return "", fmt.Errorf("openai %w (finish_reason=length)", errOutputTruncated)
+	}
+	return choice.Message.Content, nil
+}
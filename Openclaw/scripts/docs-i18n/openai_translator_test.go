@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOpenAITestServer(t *testing.T, status int, body string, requests chan<- map[string]any) *OpenAITranslator {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if requests != nil {
+			var request map[string]any
+			data, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(data, &request); err != nil {
+				t.Errorf("request body: %v", err)
+			}
+			request["authorization"] = r.Header.Get("Authorization")
+			requests <- request
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	translator, err := NewOpenAITranslator("system prompt", "", server.URL+"/v1/", "local-model", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return translator
+}
+
+func TestOpenAITranslatorPrompt(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	requests := make(chan map[string]any, 1)
+	translator := newOpenAITestServer(t, http.StatusOK, `{
+		"choices": [{"message": {"role": "assistant", "content": "你好"}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 100, "completion_tokens": 20, "prompt_tokens_details": {"cached_tokens": 40}}
+	}`, requests)
+
+	meter := &usageMeter{}
+	got, err := translator.prompt(withUsageMeter(context.Background(), meter), "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "你好" {
+		t.Errorf("prompt = %q", got)
+	}
+	if usage, want := meter.Usage(), (tokenUsage{Calls: 1, Input: 60, Output: 20, CacheRead: 40}); usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+
+	request := <-requests
+	if request["model"] != "local-model" || request["authorization"] != "Bearer sk-test" {
+		t.Errorf("request = %v", request)
+	}
+	if _, ok := request["temperature"]; ok {
+		t.Errorf("temperature sent without -temperature: %v", request["temperature"])
+	}
+	messages, _ := request["messages"].([]any)
+	if len(messages) != 2 || !strings.Contains(string(mustJSON(t, messages)), "system prompt") {
+		t.Errorf("messages = %v", messages)
+	}
+}
+
+func TestOpenAITranslatorErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		want      string
+		retryable bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"error": {"message": "slow down", "type": "rate_limit"}}`, "status 429: slow down", true},
+		{"server error", http.StatusBadGateway, `bad gateway`, "status 502", true},
+		{"client error", http.StatusBadRequest, `{"error": {"message": "unknown model"}}`, "status 400: unknown model", false},
+		{"error body", http.StatusOK, `{"error": {"message": "context window exceeded"}}`, "openai error: context window exceeded", false},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices", false},
+		{"truncated", http.StatusOK, `{"choices": [{"message": {"content": "半"}, "finish_reason": "length"}]}`, "finish_reason=length", false},
+		{"bad json", http.StatusOK, `{"choices":`, "decode failed", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translator := newOpenAITestServer(t, test.status, test.body, nil)
+			_, err := translator.prompt(context.Background(), "Hello")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("err = %v, want %q", err, test.want)
+			}
+			if got := isRetryableTranslateError(err); got != test.retryable {
+				t.Errorf("retryable = %t, want %t", got, test.retryable)
+			}
+		})
+	}
+}
+
+func TestOpenAITranslatorUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	translator, err := NewOpenAITranslator("", "", server.URL, "m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := translator.prompt(context.Background(), "Hello"); !errors.Is(err, errUpstreamUnavailable) {
+		t.Fatalf("err = %v, want errUpstreamUnavailable", err)
+	}
+}
+
+func TestOpenAITranslatorTemperature(t *testing.T) {
+	requests := make(chan map[string]any, 1)
+	translator := newOpenAITestServer(t, http.StatusOK, `{"choices": [{"message": {"content": "ok"}, "finish_reason": "stop"}]}`, requests)
+	zero := 0.0
+	translator.temperature = &zero
+	if _, err := translator.prompt(context.Background(), "Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := (<-requests)["temperature"]; !ok || value != 0.0 {
+		t.Errorf("temperature = %v, %t", value, ok)
+	}
+}
+
+func TestChatCompletionsURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://127.0.0.1:8080/v1":                   "http://127.0.0.1:8080/v1/chat/completions",
+		"http://127.0.0.1:8080/v1/":                  "http://127.0.0.1:8080/v1/chat/completions",
+		"https://api.openai.com/v1/chat/completions": "https://api.openai.com/v1/chat/completions",
+	} {
+		if got := chatCompletionsURL(endpoint); got != want {
+			t.Errorf("chatCompletionsURL(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, value any) []byte {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
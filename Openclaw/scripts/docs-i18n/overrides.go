@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override is a reviewer's translation of one source block. It is keyed like
+// a TMEntry segment (source path plus short hash of the source block), so it
+// applies wherever that block appears unchanged and lapses when the source
+// block is edited.
+type Override struct {
+	Key         string `json:"key"`
+	SourcePath  string `json:"source_path"`
+	TextHash    string `json:"text_hash"`
+	Text        string `json:"text,omitempty"`
+	Translated  string `json:"translated"`
+	MachineHash string `json:"machine_hash"`
+	SrcLang     string `json:"src_lang"`
+	TgtLang     string `json:"tgt_lang"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// OverrideStore is the per-language file of reviewed overrides
+// (docs/.i18n/<lang>.overrides.jsonl). It is safe for concurrent use.
+type OverrideStore struct {
+	mu        sync.Mutex
+	path      string
+	overrides map[string]Override
+}
+
+func LoadOverrideStore(path string) (*OverrideStore, error) {
+	store := &OverrideStore{path: path, overrides: map[string]Override{}}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+			var override Override
+			if err := json.Unmarshal([]byte(trimmed), &override); err != nil {
+				return nil, fmt.Errorf("override decode failed: %w", err)
+			}
+			if override.Key != "" {
+				store.overrides[override.Key] = override
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+func overrideKey(relPath, blockHash string) string {
+	return segmentID(relPath, blockHash)
+}
+
+func (store *OverrideStore) Get(key string) (Override, bool) {
+	if store == nil {
+		return Override{}, false
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	override, ok := store.overrides[key]
+	return override, ok
+}
+
+// Put stores an override and reports whether it changed the store.
+func (store *OverrideStore) Put(override Override) bool {
+	if store == nil || override.Key == "" {
+		return false
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if existing, ok := store.overrides[override.Key]; ok && existing.Translated == override.Translated {
+		return false
+	}
+	store.overrides[override.Key] = override
+	return true
+}
+
+// MoveSource re-keys the overrides of a renamed source page under its new
+// path; with newRel empty they are dropped. It reports how many changed.
+func (store *OverrideStore) MoveSource(oldRel, newRel string) int {
+	if store == nil {
+		return 0
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	changed := 0
+	for key, override := range store.overrides {
+		if override.SourcePath != oldRel {
+			continue
+		}
+		delete(store.overrides, key)
+		changed++
+		if newRel == "" {
+			continue
+		}
+		override.SourcePath = newRel
+		override.Key = overrideKey(newRel, override.TextHash)
+		store.overrides[override.Key] = override
+	}
+	return changed
+}
+
+func (store *OverrideStore) Save() error {
+	if store == nil || store.path == "" {
+		return nil
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(store.path), 0o755); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(store.overrides))
+	for key := range store.overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tmpPath := store.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, key := range keys {
+		payload, err := json.Marshal(store.overrides[key])
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if _, err := writer.Write(append(payload, '\n')); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, store.path)
+}
+
+// applyOverrides replaces machine-translated blocks with the reviewed
+// overrides for their source blocks. It returns the body and the hashes of
+// the source blocks that were overridden, for the x-i18n metadata. Nothing
+// is applied when the translation's blocks don't line up with the source.
+func applyOverrides(store *OverrideStore, relPath string, source markdownBlocks, translatedBody string) (string, []string) {
+	if store == nil {
+		return translatedBody, nil
+	}
+	translated := splitMarkdownBlocks(translatedBody)
+	if len(translated.Blocks) != len(source.Blocks) {
+		return translatedBody, nil
+	}
+	var applied []string
+	for i, hash := range source.hashes() {
+		override, ok := store.Get(overrideKey(relPath, hash))
+		if !ok {
+			continue
+		}
+		translated.Blocks[i] = override.Translated
+		applied = append(applied, hash)
+	}
+	if len(applied) == 0 {
+		return translatedBody, nil
+	}
+	return translated.join(), applied
+}
+
+// captureOverrides compares the translation of relPath on disk with the
+// machine output recorded in the block index and stores every block a human
+// changed. It returns how many overrides were added or updated.
+func captureOverrides(store *OverrideStore, index *BlockIndex, docsRoot, relPath, srcLang, tgtLang string) (int, error) {
+	record, ok := index.Get(relPath)
+	if !ok || len(record.Output) != len(record.Blocks) {
+		return 0, nil
+	}
+	data, err := os.ReadFile(filepath.Join(docsRoot, tgtLang, relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	frontMatter, body := splitFrontMatter(string(data))
+	frontData := map[string]any{}
+	if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err != nil || !strings.EqualFold(extractSourceHash(frontData), record.SourceHash) {
+		// Not the output the record describes (retranslated elsewhere, or
+		// the frontmatter itself was damaged).
+		return 0, nil
+	}
+	onDisk := splitMarkdownBlocks(body)
+	if len(onDisk.Blocks) != len(record.Output) {
+		return 0, fmt.Errorf("%d blocks on disk, machine output had %d; split or merged blocks can't be captured", len(onDisk.Blocks), len(record.Output))
+	}
+
+	sourceText := map[string]string{}
+	if content, err := os.ReadFile(filepath.Join(docsRoot, relPath)); err == nil {
+		_, sourceBody := splitFrontMatter(string(content))
+		source := splitMarkdownBlocks(sourceBody)
+		for i, hash := range source.hashes() {
+			sourceText[hash] = strings.TrimSpace(source.Blocks[i])
+		}
+	}
+
+	captured := 0
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, hash := range onDisk.hashes() {
+		if hash == record.Output[i] {
+			continue
+		}
+		changed := store.Put(Override{
+			Key:         overrideKey(relPath, record.Blocks[i]),
+			SourcePath:  relPath,
+			TextHash:    record.Blocks[i],
+			Text:        sourceText[record.Blocks[i]],
+			Translated:  onDisk.Blocks[i],
+			MachineHash: record.Output[i],
+			SrcLang:     srcLang,
+			TgtLang:     tgtLang,
+			UpdatedAt:   now,
+		})
+		if changed {
+			captured++
+		}
+	}
+	return captured, nil
+}
+
+// keepHandEdits captures the reviewer's edits to the current translation of
+// relPath before a run overwrites it, so they survive without a separate
+// `docs-i18n capture`. A page whose blocks were split or merged can't be
+// captured; it is refused rather than overwritten.
+func keepHandEdits(store *OverrideStore, index *BlockIndex, docsRoot, relPath, srcLang, tgtLang string) error {
+	if store == nil {
+		return nil
+	}
+	captured, err := captureOverrides(store, index, docsRoot, relPath, srcLang, tgtLang)
+	if err != nil {
+		return fmt.Errorf("%s/%s has hand edits that can't be kept (%w); fix them or delete the page to retranslate it", tgtLang, relPath, err)
+	}
+	if captured > 0 {
+		log.Printf("docs-i18n: %s: kept %d hand-edited block(s) as overrides", relPath, captured)
+	}
+	return nil
+}
+
+// runCapture implements `docs-i18n capture`: record human edits to the
+// translations of the given docs (default: every source doc) as overrides.
+func runCapture(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("capture", flag.ContinueOnError)
+	var (
+		targetLang = flags.String("lang", "zh-CN", "target language")
+		sourceLang = flags.String("src", "en", "source language")
+		docsRoot   = flags.String("docs", "docs", "docs root")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	resolvedDocsRoot, err := filepath.Abs(*docsRoot)
+	if err != nil {
+		return err
+	}
+	index, err := LoadBlockIndex(filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.blocks.jsonl", *targetLang)))
+	if err != nil {
+		return err
+	}
+	store, err := LoadOverrideStore(filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.overrides.jsonl", *targetLang)))
+	if err != nil {
+		return err
+	}
+
+	var relPaths []string
+	for _, file := range flags.Args() {
+		_, relPath, err := resolveDocsPath(resolvedDocsRoot, file)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	if len(relPaths) == 0 {
+		dirs, err := languageDirs(resolvedDocsRoot, []string{*targetLang})
+		if err != nil {
+			return err
+		}
+		relPaths, err = listDocs(resolvedDocsRoot, dirs)
+		if err != nil {
+			return err
+		}
+	}
+
+	total := 0
+	failed := 0
+	for _, relPath := range relPaths {
+		captured, err := captureOverrides(store, index, resolvedDocsRoot, relPath, *sourceLang, *targetLang)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", relPath, err)
+			failed++
+			continue
+		}
+		if captured > 0 {
+			fmt.Fprintf(stdout, "%s: captured %d override(s)\n", relPath, captured)
+		}
+		total += captured
+	}
+	fmt.Fprintf(stdout, "captured %d override(s)\n", total)
+	if err := store.Save(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d translation(s) could not be captured", failed)
+	}
+	return nil
+}
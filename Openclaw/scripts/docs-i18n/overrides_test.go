@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverridesSurviveRetranslation(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	i18nDir := filepath.Join(docsRoot, ".i18n")
+	blocksPath := filepath.Join(i18nDir, "zh-CN.blocks.jsonl")
+	overridesPath := filepath.Join(i18nDir, "zh-CN.overrides.jsonl")
+	sourcePath := filepath.Join(docsRoot, "gateway", "configuration.md")
+	outputPath := filepath.Join(docsRoot, "zh-CN", "gateway", "configuration.md")
+
+	tm, err := LoadTranslationMemory(filepath.Join(i18nDir, "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := LoadBlockIndex(blocksPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := segmentOptions{BatchSize: defaultBatchSize}
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, nil, docsRoot, sourcePath, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := blocks.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reviewer rewrites one paragraph of the machine translation.
+	machine := readNormalized(t, outputPath)
+	reviewed := strings.Replace(machine, "> ⟦Chángés táké éfféct áftér á réstárt.··········⟧", "> 重启后生效。", 1)
+	if reviewed == machine {
+		t.Fatal("fixture translation changed; update the test marker")
+	}
+	if err := os.WriteFile(outputPath, []byte(reviewed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runCapture([]string{"-docs", docsRoot, "-lang", "zh-CN"}, &out); err != nil {
+		t.Fatalf("capture: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "captured 1 override(s)") {
+		t.Fatalf("capture output:\n%s", out.String())
+	}
+
+	overrides, err := LoadOverrideStore(overridesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(mode string) {
+		t.Helper()
+		got := readNormalized(t, outputPath)
+		if !strings.Contains(got, "> 重启后生效。") {
+			t.Errorf("%s mode reverted the reviewed paragraph:\n%s", mode, got)
+		}
+		if !strings.Contains(got, "overrides:") {
+			t.Errorf("%s mode did not mark the override in x-i18n:\n%s", mode, got)
+		}
+	}
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+	check("segment")
+	if _, err := processFileDoc(context.Background(), NewFakeTranslator(), blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", true, docOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	check("doc")
+
+	// Editing the source paragraph retires the override.
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(source), "Changes take effect after a restart.", "Changes apply after a restart.", 1)
+	if err := os.WriteFile(sourcePath, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNormalized(t, outputPath); strings.Contains(got, "重启后生效") {
+		t.Errorf("override applied to a changed source block:\n%s", got)
+	}
+}
+
+func TestRunKeepsUncapturedHandEdits(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	i18nDir := filepath.Join(docsRoot, ".i18n")
+	sourcePath := filepath.Join(docsRoot, "gateway", "configuration.md")
+	outputPath := filepath.Join(docsRoot, "zh-CN", "gateway", "configuration.md")
+
+	tm, err := LoadTranslationMemory(filepath.Join(i18nDir, "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := LoadBlockIndex(filepath.Join(i18nDir, "zh-CN.blocks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := LoadOverrideStore(filepath.Join(i18nDir, "zh-CN.overrides.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := segmentOptions{BatchSize: defaultBatchSize}
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	edit := func(old, replacement string) {
+		t.Helper()
+		machine := readNormalized(t, outputPath)
+		reviewed := strings.Replace(machine, old, replacement, 1)
+		if reviewed == machine {
+			t.Fatalf("%q not in the translation; update the test marker", old)
+		}
+		if err := os.WriteFile(outputPath, []byte(reviewed), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The reviewer edits the page and nobody runs capture before the next
+	// run.
+	edit("> ⟦Chángés táké éfféct áftér á réstárt.··········⟧", "> 重启后生效。")
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNormalized(t, outputPath); !strings.Contains(got, "> 重启后生效。") {
+		t.Errorf("segment mode overwrote the hand edit:\n%s", got)
+	}
+	edit("> 重启后生效。", "> 重启之后生效。")
+	if _, err := processFileDoc(context.Background(), NewFakeTranslator(), blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", true, docOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNormalized(t, outputPath); !strings.Contains(got, "> 重启之后生效。") {
+		t.Errorf("doc mode overwrote the hand edit:\n%s", got)
+	}
+
+	// Blocks the reviewer merged can't be kept, so the page is left alone.
+	edit("> 重启之后生效。\n\n", "> 重启之后生效。")
+	before := readNormalized(t, outputPath)
+	if _, err := processFile(context.Background(), NewFakeTranslator(), tm, blocks, overrides, docsRoot, sourcePath, "en", "zh-CN", opts); err == nil {
+		t.Error("a page with merged blocks was overwritten")
+	}
+	if got := readNormalized(t, outputPath); got != before {
+		t.Errorf("refused page changed on disk:\n%s", got)
+	}
+}
@@ -11,40 +11,79 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func processFile(ctx context.Context, translator *PiTranslator, tm *TranslationMemory, docsRoot, filePath, srcLang, tgtLang string) (bool, error) {
-	absPath, relPath, err := resolveDocsPath(docsRoot, filePath)
+func processFile(ctx context.Context, translator Translator, tm *TranslationMemory, blocks *BlockIndex, overrides *OverrideStore, docsRoot, filePath, srcLang, tgtLang string, opts segmentOptions) (bool, error) {
+	source, err := opts.Sources.Load(docsRoot, filePath)
 	if err != nil {
 		return false, err
 	}
+	relPath := source.RelPath
+	if err := keepHandEdits(overrides, blocks, docsRoot, relPath, srcLang, tgtLang); err != nil {
+		return false, err
+	}
 
-	content, err := os.ReadFile(absPath)
+	frontData, err := source.FrontData()
 	if err != nil {
 		return false, err
 	}
-
-	frontMatter, body := splitFrontMatter(string(content))
-	frontData := map[string]any{}
-	if frontMatter != "" {
-		if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err != nil {
-			return false, fmt.Errorf("frontmatter parse failed for %s: %w", relPath, err)
-		}
+	if err := translateFrontMatter(ctx, translator, tm, frontData, relPath, srcLang, tgtLang); err != nil {
+		return false, err
 	}
 
-	if err := translateFrontMatter(ctx, translator, tm, frontData, relPath, srcLang, tgtLang); err != nil {
+	htmlReplacements, err := translateHTMLSpans(ctx, translator, tm, relPath, source.Body, source.HTML, srcLang, tgtLang, opts.Components)
+	if err != nil {
 		return false, err
 	}
 
-	body, err = translateHTMLBlocks(ctx, translator, body, srcLang, tgtLang)
+	segments, err := source.Segments()
 	if err != nil {
 		return false, err
 	}
+	segments = withCodeComments(segments, source.Body, relPath, opts.CodeComments)
+
+	namespace := cacheNamespace(translator.Provider(), translator.Model(), translator.Profile())
+	pending, reused := lookupSegments(tm, namespace, segments, srcLang, tgtLang, opts.References)
+	if err := translateSegments(ctx, translator, pending, srcLang, tgtLang, opts); err != nil {
+		// Keep what was translated before the failure or interruption so a
+		// resumed run doesn't pay for it again.
+		storeSegments(tm, translator, relPath, translatedSegments(pending), srcLang, tgtLang)
+		return false, fmt.Errorf("translate failed (%s): %w", relPath, err)
+	}
+	if err := enforceGlossary(ctx, translator, opts.Glossary, relPath, pending, srcLang, tgtLang); err != nil {
+		return false, fmt.Errorf("glossary retry failed (%s): %w", relPath, err)
+	}
+	storeSegments(tm, translator, relPath, append(pending, reused...), srcLang, tgtLang)
+	if err := opts.QA.Check(ctx, relPath, segmentPairs(segments, pending)); err != nil {
+		return false, err
+	}
 
-	segments, err := extractSegments(body, relPath)
+	translated := applyHTMLReplacements(source.Body, append(htmlReplacements, segmentReplacements(segments)...))
+	translatedBody := opts.Links.Localize(relPath, source.Body, translated)
+	recordDocBlocks(blocks, relPath, source.Hash, source.Front, source.Blocks, translatedBody, nil)
+	translatedBody, applied := applyOverrides(overrides, relPath, source.Blocks, translatedBody)
+	if err := validateHeadingIDs(translatedBody); err != nil {
+		return false, fmt.Errorf("%s: %w", relPath, err)
+	}
+	updatedFront, err := encodeFrontMatter(frontData, relPath, source.Content, translator.Provider(), translator.Model(), applied)
 	if err != nil {
 		return false, err
 	}
 
-	namespace := cacheNamespace()
+	outputPath := filepath.Join(docsRoot, tgtLang, relPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return false, err
+	}
+
+	output := updatedFront + translatedBody
+	return false, os.WriteFile(outputPath, []byte(output), 0o644)
+}
+
+// lookupSegments fills the segments that the translation memory already
+// covers. It returns the segments still to translate, with up to references
+// similar entries attached, and those reused from identical text elsewhere,
+// which still need storing under their own keys.
+func lookupSegments(tm *TranslationMemory, namespace string, segments []Segment, srcLang, tgtLang string, references int) ([]*Segment, []*Segment) {
+	pending := make([]*Segment, 0, len(segments))
+	var reused []*Segment
 	for i := range segments {
 		seg := &segments[i]
 		seg.CacheKey = cacheKey(namespace, srcLang, tgtLang, seg.SegmentID, seg.TextHash)
@@ -52,40 +91,48 @@ func processFile(ctx context.Context, translator *PiTranslator, tm *TranslationM
 			seg.Translated = entry.Translated
 			continue
 		}
-		translated, err := translator.Translate(ctx, seg.Text, srcLang, tgtLang)
-		if err != nil {
-			return false, fmt.Errorf("translate failed (%s): %w", relPath, err)
+		// The same text translated on another page (or before a move).
+		if entry, ok := tm.FindText(namespace, srcLang, tgtLang, seg.TextHash); ok {
+			seg.Translated = entry.Translated
+			reused = append(reused, seg)
+			continue
 		}
-		seg.Translated = translated
+		for _, match := range tm.Similar(seg.Text, srcLang, tgtLang, references) {
+			seg.References = append(seg.References, match.Entry)
+		}
+		pending = append(pending, seg)
+	}
+	return pending, reused
+}
+
+func translatedSegments(segments []*Segment) []*Segment {
+	var translated []*Segment
+	for _, seg := range segments {
+		if seg.Translated != "" {
+			translated = append(translated, seg)
+		}
+	}
+	return translated
+}
+
+func storeSegments(tm *TranslationMemory, translator Translator, sourcePath string, segments []*Segment, srcLang, tgtLang string) {
+	for _, seg := range segments {
 		entry := TMEntry{
 			CacheKey:   seg.CacheKey,
 			SegmentID:  seg.SegmentID,
-			SourcePath: relPath,
+			SourcePath: sourcePath,
 			TextHash:   seg.TextHash,
 			Text:       seg.Text,
-			Translated: translated,
-			Provider:   providerName,
-			Model:      modelVersion,
+			Translated: seg.Translated,
+			Provider:   translator.Provider(),
+			Model:      translator.Model(),
+			Profile:    translator.Profile(),
 			SrcLang:    srcLang,
 			TgtLang:    tgtLang,
 			UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
 		}
 		tm.Put(entry)
 	}
-
-	translatedBody := applyTranslations(body, segments)
-	updatedFront, err := encodeFrontMatter(frontData, relPath, content)
-	if err != nil {
-		return false, err
-	}
-
-	outputPath := filepath.Join(docsRoot, tgtLang, relPath)
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
-		return false, err
-	}
-
-	output := updatedFront + translatedBody
-	return false, os.WriteFile(outputPath, []byte(output), 0o644)
 }
 
 func splitFrontMatter(content string) (string, string) {
@@ -114,18 +161,24 @@ func splitFrontMatter(content string) (string, string) {
 	return front, body
 }
 
-func encodeFrontMatter(frontData map[string]any, relPath string, source []byte) (string, error) {
+// encodeFrontMatter renders the frontmatter with the x-i18n provenance block.
+// overrides lists the source blocks replaced by reviewed overrides.
+func encodeFrontMatter(frontData map[string]any, relPath string, source []byte, provider, model string, overrides []string) (string, error) {
 	if frontData == nil {
 		frontData = map[string]any{}
 	}
-	frontData["x-i18n"] = map[string]any{
+	meta := map[string]any{
 		"source_path":  relPath,
 		"source_hash":  hashBytes(source),
-		"provider":     providerName,
-		"model":        modelVersion,
+		"provider":     provider,
+		"model":        model,
 		"workflow":     workflowVersion,
 		"generated_at": time.Now().UTC().Format(time.RFC3339),
 	}
+	if len(overrides) > 0 {
+		meta["overrides"] = overrides
+	}
+	frontData["x-i18n"] = meta
 	encoded, err := yaml.Marshal(frontData)
 	if err != nil {
 		return "", err
@@ -133,7 +186,7 @@ func encodeFrontMatter(frontData map[string]any, relPath string, source []byte)
 	return fmt.Sprintf("---\n%s---\n\n", string(encoded)), nil
 }
 
-func translateFrontMatter(ctx context.Context, translator *PiTranslator, tm *TranslationMemory, data map[string]any, relPath, srcLang, tgtLang string) error {
+func translateFrontMatter(ctx context.Context, translator Translator, tm *TranslationMemory, data map[string]any, relPath, srcLang, tgtLang string) error {
 	if len(data) == 0 {
 		return nil
 	}
@@ -170,19 +223,31 @@ func translateFrontMatter(ctx context.Context, translator *PiTranslator, tm *Tra
 	return nil
 }
 
-func translateSnippet(ctx context.Context, translator *PiTranslator, tm *TranslationMemory, segmentID, textValue, srcLang, tgtLang string) (string, error) {
+// translateSnippet translates a piece of text outside the segments, such as
+// a front matter field, through the translation memory under segmentID. A nil
+// tm translates without caching, as pseudo mode does.
+func translateSnippet(ctx context.Context, translator Translator, tm *TranslationMemory, segmentID, textValue, srcLang, tgtLang string) (string, error) {
 	if strings.TrimSpace(textValue) == "" {
 		return textValue, nil
 	}
-	namespace := cacheNamespace()
+	if tm == nil {
+		return translator.Translate(ctx, textValue, srcLang, tgtLang, promptHints{})
+	}
+	namespace := cacheNamespace(translator.Provider(), translator.Model(), translator.Profile())
 	textHash := hashText(textValue)
 	ck := cacheKey(namespace, srcLang, tgtLang, segmentID, textHash)
 	if entry, ok := tm.Get(ck); ok {
 		return entry.Translated, nil
 	}
-	translated, err := translator.Translate(ctx, textValue, srcLang, tgtLang)
-	if err != nil {
-		return "", err
+	translated := ""
+	if entry, ok := tm.FindText(namespace, srcLang, tgtLang, textHash); ok {
+		translated = entry.Translated
+	} else {
+		var err error
+		translated, err = translator.Translate(ctx, textValue, srcLang, tgtLang, promptHints{})
+		if err != nil {
+			return "", err
+		}
 	}
 	entry := TMEntry{
 		CacheKey:   ck,
@@ -191,8 +256,9 @@ func translateSnippet(ctx context.Context, translator *PiTranslator, tm *Transla
 		TextHash:   textHash,
 		Text:       textValue,
 		Translated: translated,
-		Provider:   providerName,
-		Model:      modelVersion,
+		Provider:   translator.Provider(),
+		Model:      translator.Model(),
+		Profile:    translator.Profile(),
 		SrcLang:    srcLang,
 		TgtLang:    tgtLang,
 		UpdatedAt:  time.Now().UTC().Format(time.RFC3339),
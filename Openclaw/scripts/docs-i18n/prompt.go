@@ -1,10 +1,126 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"reflect"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
+// promptProfile holds the language-specific parts of the system prompt. The
+// built-in profiles can be replaced field by field with
+// docs/.i18n/prompts/<lang>.yaml.
+type promptProfile struct {
+	// Label names the language in the prompt ("Simplified Chinese").
+	Label string `yaml:"label" json:"label"`
+	// Rules follow the instruction to translate all English prose.
+	Rules []string `yaml:"rules" json:"rules"`
+	// Style notes come after the shared formatting rules.
+	Style []string `yaml:"style" json:"style"`
+	// Quotes is the quotation mark convention.
+	Quotes string `yaml:"quotes" json:"quotes"`
+	// Notes follow the quote convention.
+	Notes []string `yaml:"notes" json:"notes"`
+	// ProductNames are kept in English.
+	ProductNames []string `yaml:"product_names" json:"product_names"`
+	// Terminology lines follow the product names.
+	Terminology []string `yaml:"terminology" json:"terminology"`
+	// KeepEnglish lists other terms kept in English.
+	KeepEnglish []string `yaml:"keep_english" json:"keep_english"`
+}
+
+var defaultProductNames = []string{"OpenClaw", "Pi", "WhatsApp", "Telegram", "Discord", "iMessage", "Slack", "Microsoft Teams", "Google Chat", "Signal"}
+
+var defaultKeepEnglish = []string{"Skills", "local loopback", "Tailscale"}
+
+// builtinPromptProfile returns the profile used when no file overrides it.
+// Keep the zh-CN profile as stable as possible; it has lots of tuning baked
+// into the wording.
+func builtinPromptProfile(lang string) promptProfile {
+	switch {
+	case strings.EqualFold(lang, "zh-CN"):
+		return promptProfile{
+			Label: "Simplified Chinese",
+			Rules: []string{"All prose must be Chinese. If any English sentence remains outside code/URLs/product names, it is wrong."},
+			Style: []string{
+				"Use fluent, idiomatic technical Chinese; avoid slang or jokes.",
+				"Use neutral documentation tone; prefer “你/你的”, avoid “您/您的”.",
+				"Insert a space between Latin characters and CJK text (W3C CLREQ), e.g., “Gateway 网关”, “Skills 配置”.",
+			},
+			Quotes:       "Use Chinese quotation marks “ and ” for Chinese prose; keep ASCII quotes inside code spans/blocks or literal CLI/keys.",
+			ProductNames: defaultProductNames,
+			Terminology:  []string{"For the OpenClaw Gateway, use “Gateway 网关”."},
+			KeepEnglish:  defaultKeepEnglish,
+		}
+	case strings.EqualFold(lang, "ja-JP"):
+		return promptProfile{
+			Label: "Japanese",
+			Rules: []string{"All prose must be Japanese. If any English sentence remains outside code/URLs/product names, it is wrong."},
+			Style: []string{
+				"Use fluent, idiomatic technical Japanese; avoid slang or jokes.",
+				"Use neutral documentation tone; avoid overly formal honorifics (e.g., avoid “〜でございます”).",
+			},
+			Quotes:       "Use Japanese quotation marks 「 and 」 for Japanese prose; keep ASCII quotes inside code spans/blocks or literal CLI/keys.",
+			Notes:        []string{"Do not add or remove spacing around Latin text just because it borders Japanese; keep spacing stable unless required by Japanese grammar."},
+			ProductNames: defaultProductNames,
+			KeepEnglish:  defaultKeepEnglish,
+		}
+	default:
+		return promptProfile{
+			Label: prettyLanguageLabel(lang),
+			Rules: []string{"If any English sentence remains outside code/URLs/product names, it is likely wrong."},
+			Style: []string{
+				"Use fluent, idiomatic technical language in the target language; avoid slang or jokes.",
+				"Use neutral documentation tone.",
+			},
+			ProductNames: defaultProductNames,
+			KeepEnglish:  defaultKeepEnglish,
+		}
+	}
+}
+
+// LoadPromptProfile reads the profile for lang from path, starting from the
+// built-in profile so a file only needs the fields it changes. A missing file
+// yields the built-in profile.
+func LoadPromptProfile(path, lang string) (promptProfile, error) {
+	profile := builtinPromptProfile(lang)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return profile, nil
+		}
+		return promptProfile{}, err
+	}
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return promptProfile{}, fmt.Errorf("prompt profile parse failed: %w", err)
+	}
+	if strings.TrimSpace(profile.Label) == "" {
+		profile.Label = prettyLanguageLabel(lang)
+	}
+	return profile, nil
+}
+
+// profileHash identifies a profile that differs from the built-in one for
+// lang, for the cache namespace. It is empty for the built-in profile, so
+// existing translation memory stays valid until a profile changes.
+func profileHash(profile promptProfile, lang string) string {
+	if reflect.DeepEqual(profile, builtinPromptProfile(lang)) {
+		return ""
+	}
+	payload, err := json.Marshal(profile)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])[:16]
+}
+
 func prettyLanguageLabel(lang string) string {
 	trimmed := strings.TrimSpace(lang)
 	if trimmed == "" {
@@ -22,113 +138,79 @@ func prettyLanguageLabel(lang string) string {
 	}
 }
 
-func translationPrompt(srcLang, tgtLang string, glossary []GlossaryEntry) string {
-	srcLabel := prettyLanguageLabel(srcLang)
-	tgtLabel := prettyLanguageLabel(tgtLang)
-	glossaryBlock := buildGlossaryPrompt(glossary)
+func translationPrompt(srcLang string, profile promptProfile, glossary []GlossaryEntry) string {
+	var rules []string
+	rules = append(rules,
+		"Output ONLY the translated text. No preamble, no questions, no commentary.",
+		"Translate all English prose; do not leave English unless it is code, a URL, or a product name.",
+	)
+	rules = append(rules, profile.Rules...)
+	rules = append(rules, sharedPromptRules...)
+	rules = append(rules, profile.Style...)
+	if profile.Quotes != "" {
+		rules = append(rules, profile.Quotes)
+	}
+	rules = append(rules, profile.Notes...)
+	if len(profile.ProductNames) > 0 {
+		rules = append(rules, fmt.Sprintf("Keep product names in English: %s.", strings.Join(profile.ProductNames, ", ")))
+	}
+	rules = append(rules, profile.Terminology...)
+	if len(profile.KeepEnglish) > 0 {
+		rules = append(rules, fmt.Sprintf("Keep these terms in English: %s.", strings.Join(profile.KeepEnglish, ", ")))
+	}
+	rules = append(rules, "Never output an empty response; if unsure, return the source text unchanged.")
 
-	switch {
-	case strings.EqualFold(tgtLang, "zh-CN"):
-		// Keep this prompt as stable as possible; it has lots of tuning baked into the wording.
-		return strings.TrimSpace(fmt.Sprintf(zhCNPromptTemplate, srcLabel, tgtLabel, glossaryBlock))
-	case strings.EqualFold(tgtLang, "ja-JP"):
-		return strings.TrimSpace(fmt.Sprintf(jaJPPromptTemplate, srcLabel, tgtLabel, glossaryBlock))
-	default:
-		return strings.TrimSpace(fmt.Sprintf(genericPromptTemplate, srcLabel, tgtLabel, glossaryBlock))
+	var ruleLines strings.Builder
+	for _, rule := range rules {
+		ruleLines.WriteString("- " + rule + "\n")
 	}
+	return strings.TrimSpace(fmt.Sprintf(promptTemplate, prettyLanguageLabel(srcLang), profile.Label, ruleLines.String(), buildGlossaryPrompt(glossary)))
 }
 
-const zhCNPromptTemplate = `You are a translation function, not a chat assistant.
-Translate from %s to %s.
-
-Rules:
-- Output ONLY the translated text. No preamble, no questions, no commentary.
-- Translate all English prose; do not leave English unless it is code, a URL, or a product name.
-- All prose must be Chinese. If any English sentence remains outside code/URLs/product names, it is wrong.
-- If the input contains <frontmatter> and <body> tags, keep them exactly and output exactly one of each.
-- Translate only the contents inside those tags.
-- Preserve YAML structure inside <frontmatter>; translate only values.
-- Preserve all [[[FM_*]]] markers exactly and translate only the text between each START/END pair.
-- Translate headings/labels like "Exit codes" and "Optional scripts".
-- Preserve Markdown syntax exactly (headings, lists, tables, emphasis).
-- Preserve HTML tags and attributes exactly.
-- Do not translate code spans/blocks, config keys, CLI flags, or env vars.
-- Do not alter URLs or anchors.
-- Preserve placeholders exactly: __OC_I18N_####__.
-- Do not remove, reorder, or summarize content.
-- Use fluent, idiomatic technical Chinese; avoid slang or jokes.
-- Use neutral documentation tone; prefer “你/你的”, avoid “您/您的”.
-- Insert a space between Latin characters and CJK text (W3C CLREQ), e.g., “Gateway 网关”, “Skills 配置”.
-- Use Chinese quotation marks “ and ” for Chinese prose; keep ASCII quotes inside code spans/blocks or literal CLI/keys.
-- Keep product names in English: OpenClaw, Pi, WhatsApp, Telegram, Discord, iMessage, Slack, Microsoft Teams, Google Chat, Signal.
-- For the OpenClaw Gateway, use “Gateway 网关”.
-- Keep these terms in English: Skills, local loopback, Tailscale.
-- Never output an empty response; if unsure, return the source text unchanged.
-
-%s
-
-If the input is empty, output empty.
-If the input contains only placeholders, output it unchanged.`
+var sharedPromptRules = []string{
+	"If the input contains <frontmatter> and <body> tags, keep them exactly and output exactly one of each.",
+	"Translate only the contents inside those tags.",
+	"Preserve YAML structure inside <frontmatter>; translate only values.",
+	"Preserve all [[[FM_*]]] markers exactly and translate only the text between each START/END pair.",
+	`If the input contains <seg id="N"> blocks, translate each block on its own and keep every <seg id="N"> and </seg> tag exactly.`,
+	"If the input starts with a <context> block, use it only as reference for wording and terminology; do not output it.",
+	`Translate headings/labels like "Exit codes" and "Optional scripts".`,
+	"Preserve Markdown syntax exactly (headings, lists, tables, emphasis).",
+	"Preserve HTML tags and attributes exactly.",
+	"Do not translate code spans/blocks, config keys, CLI flags, or env vars.",
+	"Do not alter URLs or anchors.",
+	"Preserve placeholders exactly: __OC_I18N_####__.",
+	"Do not remove, reorder, or summarize content.",
+}
 
-const jaJPPromptTemplate = `You are a translation function, not a chat assistant.
+const promptTemplate = `You are a translation function, not a chat assistant.
 Translate from %s to %s.
 
 Rules:
-- Output ONLY the translated text. No preamble, no questions, no commentary.
-- Translate all English prose; do not leave English unless it is code, a URL, or a product name.
-- All prose must be Japanese. If any English sentence remains outside code/URLs/product names, it is wrong.
-- If the input contains <frontmatter> and <body> tags, keep them exactly and output exactly one of each.
-- Translate only the contents inside those tags.
-- Preserve YAML structure inside <frontmatter>; translate only values.
-- Preserve all [[[FM_*]]] markers exactly and translate only the text between each START/END pair.
-- Translate headings/labels like "Exit codes" and "Optional scripts".
-- Preserve Markdown syntax exactly (headings, lists, tables, emphasis).
-- Preserve HTML tags and attributes exactly.
-- Do not translate code spans/blocks, config keys, CLI flags, or env vars.
-- Do not alter URLs or anchors.
-- Preserve placeholders exactly: __OC_I18N_####__.
-- Do not remove, reorder, or summarize content.
-- Use fluent, idiomatic technical Japanese; avoid slang or jokes.
-- Use neutral documentation tone; avoid overly formal honorifics (e.g., avoid “〜でございます”).
-- Use Japanese quotation marks 「 and 」 for Japanese prose; keep ASCII quotes inside code spans/blocks or literal CLI/keys.
-- Do not add or remove spacing around Latin text just because it borders Japanese; keep spacing stable unless required by Japanese grammar.
-- Keep product names in English: OpenClaw, Pi, WhatsApp, Telegram, Discord, iMessage, Slack, Microsoft Teams, Google Chat, Signal.
-- Keep these terms in English: Skills, local loopback, Tailscale.
-- Never output an empty response; if unsure, return the source text unchanged.
-
+%s
 %s
 
 If the input is empty, output empty.
 If the input contains only placeholders, output it unchanged.`
 
-const genericPromptTemplate = `You are a translation function, not a chat assistant.
-Translate from %s to %s.
+// backTranslationPrompt translates a translation back into the source
+// language for the QA pass. It asks for a literal rendering so that meaning
+// the translation lost or added shows up in the comparison.
+func backTranslationPrompt(lang, srcLang string) string {
+	return fmt.Sprintf(backTranslationTemplate, prettyLanguageLabel(lang), prettyLanguageLabel(srcLang))
+}
+
+const backTranslationTemplate = `You are a translation function, not a chat assistant.
+Translate from %[1]s to %[2]s. The result is compared with the original to check the translation.
 
 Rules:
 - Output ONLY the translated text. No preamble, no questions, no commentary.
-- Translate all English prose; do not leave English unless it is code, a URL, or a product name.
-- If any English sentence remains outside code/URLs/product names, it is likely wrong.
-- If the input contains <frontmatter> and <body> tags, keep them exactly and output exactly one of each.
-- Translate only the contents inside those tags.
-- Preserve YAML structure inside <frontmatter>; translate only values.
-- Preserve all [[[FM_*]]] markers exactly and translate only the text between each START/END pair.
-- Translate headings/labels like "Exit codes" and "Optional scripts".
-- Preserve Markdown syntax exactly (headings, lists, tables, emphasis).
-- Preserve HTML tags and attributes exactly.
-- Do not translate code spans/blocks, config keys, CLI flags, or env vars.
-- Do not alter URLs or anchors.
+- Translate literally; do not fix, improve, complete or shorten the text, so changes in meaning stay visible.
+- Leave text that is already in %[2]s as it is.
+- Preserve Markdown syntax, HTML tags, code spans, URLs and anchors exactly.
 - Preserve placeholders exactly: __OC_I18N_####__.
-- Do not remove, reorder, or summarize content.
-- Use fluent, idiomatic technical language in the target language; avoid slang or jokes.
-- Use neutral documentation tone.
-- Keep product names in English: OpenClaw, Pi, WhatsApp, Telegram, Discord, iMessage, Slack, Microsoft Teams, Google Chat, Signal.
-- Keep these terms in English: Skills, local loopback, Tailscale.
-- Never output an empty response; if unsure, return the source text unchanged.
-
-%s
-
-If the input is empty, output empty.
-If the input contains only placeholders, output it unchanged.`
+- If the input contains <seg id="N"> blocks, translate each block on its own and keep every <seg id="N"> and </seg> tag exactly.
+- Never output an empty response; if unsure, return the text unchanged.`
 
 func buildGlossaryPrompt(glossary []GlossaryEntry) string {
 	if len(glossary) == 0 {
@@ -137,10 +219,24 @@ func buildGlossaryPrompt(glossary []GlossaryEntry) string {
 	var lines []string
 	lines = append(lines, "Preferred translations (use when natural):")
 	for _, entry := range glossary {
-		if entry.Source == "" || entry.Target == "" {
+		if entry.Source == "" || (entry.Target == "" && !entry.DoNotTranslate) {
 			continue
 		}
-		lines = append(lines, fmt.Sprintf("- %s -> %s", entry.Source, entry.Target))
+		line := fmt.Sprintf("- %s -> %s", entry.Source, entry.want())
+		var hints []string
+		if entry.DoNotTranslate {
+			hints = append(hints, "keep as is")
+		}
+		if len(entry.Forbidden) > 0 {
+			hints = append(hints, "never: "+strings.Join(entry.Forbidden, ", "))
+		}
+		if entry.Notes != "" {
+			hints = append(hints, entry.Notes)
+		}
+		if len(hints) > 0 {
+			line += " (" + strings.Join(hints, "; ") + ")"
+		}
+		lines = append(lines, line)
 	}
 	return strings.Join(lines, "\n")
 }
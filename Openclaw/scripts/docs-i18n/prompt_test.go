@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPromptProfile(t *testing.T) {
+	dir := t.TempDir()
+	builtin, err := LoadPromptProfile(filepath.Join(dir, "zh-CN.yaml"), "zh-CN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileHash(builtin, "zh-CN") != "" {
+		t.Fatal("built-in profile changed the cache namespace")
+	}
+
+	path := filepath.Join(dir, "ko-KR.yaml")
+	profile := `label: Korean
+quotes: Use Korean quotation marks “ and ” for Korean prose.
+product_names: [OpenClaw, Pi]
+`
+	if err := os.WriteFile(path, []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	korean, err := LoadPromptProfile(path, "ko-KR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := translationPrompt("en", korean, nil)
+	for _, want := range []string{
+		"Translate from English to Korean.",
+		"- Use Korean quotation marks “ and ” for Korean prose.",
+		"- Keep product names in English: OpenClaw, Pi.",
+		// Fields the file leaves out keep their built-in values.
+		"- Keep these terms in English: Skills, local loopback, Tailscale.",
+		"- Use neutral documentation tone.",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+
+	hash := profileHash(korean, "ko-KR")
+	if hash == "" || cacheNamespace("pi", "m", hash) == cacheNamespace("pi", "m", "") {
+		t.Fatalf("edited profile kept the built-in cache namespace (hash %q)", hash)
+	}
+	korean.Notes = append(korean.Notes, "Use the 합니다 style.")
+	if profileHash(korean, "ko-KR") == hash {
+		t.Fatal("profile edit did not change the hash")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	pseudoOpen  = "⟦"
+	pseudoClose = "⟧"
+	pseudoPad   = "·"
+)
+
+// pseudoProtectedRe matches tokens the pseudo-localizer must copy verbatim:
+// placeholders, frontmatter markers, HTML/XML tags, entities and bare URLs.
+var pseudoProtectedRe = regexp.MustCompile(`__OC_I18N_\d+__|\[\[\[FM_[A-Z0-9_]+\]\]\]|</?[A-Za-z][^<>]*>|&[#A-Za-z0-9]+;|https?://[^\s)>\]]+`)
+
+var frontmatterStartRe = regexp.MustCompile(`\[\[\[FM_([A-Z0-9_]+?)_START\]\]\]`)
+
+var pseudoVowels = map[rune]rune{
+	'a': 'á', 'e': 'é', 'i': 'í', 'o': 'ó', 'u': 'ú', 'y': 'ý',
+	'A': 'Á', 'E': 'É', 'I': 'Í', 'O': 'Ó', 'U': 'Ú', 'Y': 'Ý',
+}
+
+// pseudoLocalize deterministically rewrites prose so untranslated or
+// truncated strings stand out: vowels are accented, the text is padded by
+// roughly a third and wrapped in ⟦…⟧. Protected tokens are left untouched,
+// and text made only of protected tokens is returned unchanged.
+func pseudoLocalize(text string) string {
+	prefix, core, suffix := splitWhitespace(text)
+	if core == "" {
+		return text
+	}
+	matches := pseudoProtectedRe.FindAllStringIndex(core, -1)
+	var out strings.Builder
+	letters := 0
+	pos := 0
+	for _, span := range matches {
+		letters += pseudoAccent(&out, core[pos:span[0]])
+		out.WriteString(core[span[0]:span[1]])
+		pos = span[1]
+	}
+	letters += pseudoAccent(&out, core[pos:])
+	if letters == 0 {
+		return text
+	}
+	padding := strings.Repeat(pseudoPad, (letters+2)/3)
+	return prefix + pseudoOpen + out.String() + padding + pseudoClose + suffix
+}
+
+func pseudoAccent(out *strings.Builder, text string) int {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+		if accented, ok := pseudoVowels[r]; ok {
+			out.WriteRune(accented)
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return letters
+}
+
+// pseudoLocalizeMarkers rewrites only the text between [[[FM_*_START]]] and
+// [[[FM_*_END]]] pairs, leaving YAML keys and structure as they were.
+func pseudoLocalizeMarkers(front string) string {
+	var out strings.Builder
+	pos := 0
+	for _, span := range frontmatterStartRe.FindAllStringSubmatchIndex(front, -1) {
+		if span[0] < pos {
+			continue
+		}
+		end := "[[[FM_" + front[span[2]:span[3]] + "_END]]]"
+		endIndex := strings.Index(front[span[1]:], end)
+		if endIndex == -1 {
+			continue
+		}
+		endIndex += span[1]
+		out.WriteString(front[pos:span[1]])
+		out.WriteString(pseudoLocalize(front[span[1]:endIndex]))
+		pos = endIndex
+	}
+	out.WriteString(front[pos:])
+	return out.String()
+}
+
+// pseudoLocalizeBody rewrites every translatable segment of a Markdown body,
+// using the same segment extraction as segment mode.
+func pseudoLocalizeBody(body string) (string, error) {
+	segments, err := extractSegments(body, "")
+	if err != nil {
+		return "", err
+	}
+	for i := range segments {
+		segments[i].Translated = pseudoLocalize(segments[i].Text)
+	}
+	return applyTranslations(body, segments), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pseudoProcessor adapts processFilePseudo to the doc runners.
+func pseudoProcessor(components componentAttrs, sources *sourceCache) docProcessor {
+	return func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
+		return processFilePseudo(ctx, translator, sources, docsRoot, filePath, srcLang, tgtLang, overwrite, components)
+	}
+}
+
+// processFilePseudo writes a pseudo-localized copy of a doc. It goes through
+// the same frontmatter markers, HTML block handling and segment extraction as
+// a real translation, so the output has the same structure, but it never
+// talks to a model and never touches the translation memory.
+func processFilePseudo(ctx context.Context, translator Translator, sources *sourceCache, docsRoot, filePath, srcLang, tgtLang string, overwrite bool, components componentAttrs) (bool, error) {
+	source, err := sources.Load(docsRoot, filePath)
+	if err != nil {
+		return false, err
+	}
+	relPath := source.RelPath
+
+	outputPath := filepath.Join(docsRoot, tgtLang, relPath)
+	if !overwrite {
+		protected, err := isNonPseudoTranslation(outputPath, translator.Provider())
+		if err != nil {
+			return false, err
+		}
+		if protected {
+			return true, nil
+		}
+	}
+
+	frontData, err := source.FrontData()
+	if err != nil {
+		return false, err
+	}
+	frontTemplate, markers := buildFrontmatterTemplate(frontData)
+	if err := applyFrontmatterTranslations(frontData, markers, pseudoLocalizeMarkers(frontTemplate)); err != nil {
+		return false, fmt.Errorf("frontmatter pseudo-localization failed for %s: %w", relPath, err)
+	}
+
+	replacements, err := translateHTMLSpans(ctx, translator, nil, relPath, source.Body, source.HTML, srcLang, tgtLang, components)
+	if err != nil {
+		return false, err
+	}
+	segments, err := source.Segments()
+	if err != nil {
+		return false, err
+	}
+	for i := range segments {
+		segments[i].Translated = pseudoLocalize(segments[i].Text)
+	}
+	body := applyHTMLReplacements(source.Body, append(replacements, segmentReplacements(segments)...))
+
+	updatedFront, err := encodeFrontMatter(frontData, relPath, source.Content, translator.Provider(), translator.Model(), nil)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return false, err
+	}
+	return false, os.WriteFile(outputPath, []byte(updatedFront+body), 0o644)
+}
+
+// isNonPseudoTranslation reports whether outputPath holds a translation made
+// by some other provider, which pseudo mode must not clobber.
+func isNonPseudoTranslation(outputPath, pseudoProvider string) (bool, error) {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	frontMatter, _ := splitFrontMatter(string(data))
+	frontData := map[string]any{}
+	if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err != nil {
+		return true, nil
+	}
+	xi, ok := frontData["x-i18n"].(map[string]any)
+	if !ok {
+		return true, nil
+	}
+	provider, _ := xi["provider"].(string)
+	return provider != pseudoProvider, nil
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// qaDriftThreshold is the drift at which a back-translated segment is
+	// listed for review. Faithful translations rarely come back word for
+	// word, so moderate drift is normal.
+	qaDriftThreshold = 0.6
+	// qaMaxIssues caps the segments listed per page, worst first.
+	qaMaxIssues = 10
+	// qaLeakWords is how many consecutive Latin words count as an English
+	// sentence left in a non-Latin translation.
+	qaLeakWords = 4
+)
+
+// qaPair is a source text and its translation. A Reused translation came
+// from the translation memory; it is checked for leaks but not
+// back-translated again: it keeps the result of the run that translated it.
+type qaPair struct {
+	Source     string
+	Translated string
+	Reused     bool
+}
+
+// qaIssue is a segment worth a reviewer's look.
+type qaIssue struct {
+	Source          string   `json:"source"`
+	Translated      string   `json:"translated"`
+	BackTranslation string   `json:"back_translation,omitempty"`
+	Drift           float64  `json:"drift,omitempty"`
+	Leaked          []string `json:"leaked,omitempty"`
+}
+
+// qaFileReport is the QA result of one translated page.
+type qaFileReport struct {
+	Path      string `json:"path"`
+	CheckedAt string `json:"checked_at"`
+	Segments  int    `json:"segments"`
+	Sampled   int    `json:"sampled"`
+	// Drift is the mean drift of the back-translated segments.
+	Drift float64 `json:"drift"`
+	// Leaks counts segments with English left in the translation.
+	Leaks int `json:"leaks"`
+	// Score orders pages for review; higher is worse.
+	Score  float64   `json:"score"`
+	Issues []qaIssue `json:"issues,omitempty"`
+	// Error is why back-translation failed; the drift covers the segments
+	// back-translated before it.
+	Error string `json:"error,omitempty"`
+	// Samples is the drift of each back-translated segment by the hash of
+	// its source and translation, so segments reused by a later run keep
+	// their result.
+	Samples map[string]float64 `json:"samples,omitempty"`
+}
+
+type qaReport struct {
+	Lang    string         `json:"lang"`
+	SrcLang string         `json:"src_lang"`
+	Files   []qaFileReport `json:"files"`
+}
+
+// qaChecker estimates translation quality without a reviewer: it
+// back-translates a sample of segments and measures how far the result
+// drifts from the source, and checks translations into non-Latin scripts for
+// English left untranslated. Results are kept per page for the report. A nil
+// checker checks nothing.
+type qaChecker struct {
+	path    string
+	lang    string
+	srcLang string
+	// rate is the fraction of segments back-translated.
+	rate float64
+	// leaks checks for English sentences; only meaningful when the target
+	// language doesn't use the Latin script.
+	leaks bool
+	// keep is stripped before the leak check: product names and terms the
+	// translation keeps in English.
+	keep []string
+
+	translators *spareTranslators
+
+	mu    sync.Mutex
+	files map[string]qaFileReport
+	// previous is the saved report by page, loaded on first use.
+	previous map[string]qaFileReport
+}
+
+// newQAChecker checks translations into lang, back-translating rate of the
+// segments with translators from newTranslator. The report is kept at path.
+func newQAChecker(path, lang, srcLang string, rate float64, profile promptProfile, glossary []GlossaryEntry, newTranslator func() (Translator, error)) *qaChecker {
+	checker := &qaChecker{
+		path:        path,
+		lang:        lang,
+		srcLang:     srcLang,
+		rate:        rate,
+		leaks:       nonLatinLanguage(lang),
+		translators: newSpareTranslators(newTranslator),
+		files:       map[string]qaFileReport{},
+	}
+	checker.keep = append(checker.keep, profile.ProductNames...)
+	checker.keep = append(checker.keep, profile.KeepEnglish...)
+	for _, entry := range glossary {
+		if want := strings.TrimSpace(entry.want()); want != "" {
+			checker.keep = append(checker.keep, want)
+		}
+	}
+	// Longer terms first, so "Microsoft Teams" goes before "Teams".
+	sort.SliceStable(checker.keep, func(i, j int) bool {
+		return len(checker.keep[i]) > len(checker.keep[j])
+	})
+	return checker
+}
+
+// nonLatinLanguage reports whether lang is written in a script other than
+// Latin, so English words in its prose stand out.
+func nonLatinLanguage(lang string) bool {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(lang)), "-")
+	switch base {
+	case "zh", "ja", "ko", "ru", "uk", "bg", "sr", "el", "ar", "fa", "he", "hi", "th":
+		return true
+	}
+	return false
+}
+
+// Check runs the QA pass over the translated pairs of relPath and records
+// the page's result. Back-translations are billed to ctx like any other
+// request and sent in batches. A failed back-translation is recorded in the
+// report instead of failing the page.
+func (checker *qaChecker) Check(ctx context.Context, relPath string, pairs []qaPair) error {
+	if checker == nil {
+		return nil
+	}
+	report := qaFileReport{Path: relPath, CheckedAt: time.Now().UTC().Format(time.RFC3339), Samples: map[string]float64{}}
+	previous := checker.previousReport(relPath)
+	var checked []qaIssue
+	var back []*Segment
+	var backIssues []int
+	var drift float64
+	for _, pair := range pairs {
+		if !hasLetters(pair.Source) || strings.TrimSpace(pair.Translated) == "" {
+			continue
+		}
+		report.Segments++
+		issue := qaIssue{Source: pair.Source, Translated: pair.Translated}
+		if checker.leaks {
+			if issue.Leaked = checker.leakedEnglish(pair.Translated); len(issue.Leaked) > 0 {
+				report.Leaks++
+			}
+		}
+		if checker.sampled(pair.Source) && !pair.Reused {
+			back = append(back, &Segment{Text: pair.Translated})
+			backIssues = append(backIssues, len(checked))
+		} else if sample, ok := previous.Samples[qaSampleKey(pair.Source, pair.Translated)]; ok && pair.Reused {
+			report.Sampled++
+			report.Samples[qaSampleKey(pair.Source, pair.Translated)] = sample
+			issue.Drift = sample
+			issue.BackTranslation = previous.backTranslation(pair)
+			drift += sample
+		}
+		checked = append(checked, issue)
+	}
+	if err := checker.backTranslate(ctx, back); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.Error = err.Error()
+		log.Printf("docs-i18n: qa %s: back-translation failed: %v", jobKey(checker.lang, relPath), err)
+	}
+	for i, seg := range back {
+		if seg.Translated == "" {
+			continue
+		}
+		issue := &checked[backIssues[i]]
+		report.Sampled++
+		issue.BackTranslation = seg.Translated
+		issue.Drift = roundScore(1 - textSimilarity(issue.Source, seg.Translated))
+		report.Samples[qaSampleKey(issue.Source, issue.Translated)] = issue.Drift
+		drift += issue.Drift
+	}
+	var issues []qaIssue
+	for _, issue := range checked {
+		if len(issue.Leaked) > 0 || issue.Drift >= qaDriftThreshold {
+			issues = append(issues, issue)
+		}
+	}
+	if report.Sampled > 0 {
+		report.Drift = roundScore(drift / float64(report.Sampled))
+	}
+	if report.Segments > 0 {
+		report.Score = roundScore(report.Drift + float64(report.Leaks)/float64(report.Segments))
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if (len(issues[i].Leaked) > 0) != (len(issues[j].Leaked) > 0) {
+			return len(issues[i].Leaked) > 0
+		}
+		return issues[i].Drift > issues[j].Drift
+	})
+	if len(issues) > qaMaxIssues {
+		issues = issues[:qaMaxIssues]
+	}
+	report.Issues = issues
+	if len(issues) > 0 {
+		log.Printf("docs-i18n: qa %s: %d segments to review (drift=%.2f leaks=%d)", jobKey(checker.lang, relPath), len(issues), report.Drift, report.Leaks)
+	}
+
+	checker.mu.Lock()
+	checker.files[relPath] = report
+	checker.mu.Unlock()
+	return nil
+}
+
+// previousReport returns the saved report of relPath, if any. A report that
+// can't be read carries nothing over; Save reports the error.
+func (checker *qaChecker) previousReport(relPath string) qaFileReport {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	if checker.previous == nil {
+		checker.previous = map[string]qaFileReport{}
+		if report, err := readQAReport(checker.path); err == nil {
+			for _, file := range report.Files {
+				checker.previous[file.Path] = file
+			}
+		}
+	}
+	return checker.previous[relPath]
+}
+
+// backTranslation returns the back-translation listed for pair, if it was an
+// issue.
+func (file qaFileReport) backTranslation(pair qaPair) string {
+	for _, issue := range file.Issues {
+		if issue.Source == pair.Source && issue.Translated == pair.Translated {
+			return issue.BackTranslation
+		}
+	}
+	return ""
+}
+
+func qaSampleKey(source, translated string) string {
+	return shortHash(hashText(source + "\x00" + translated))
+}
+
+// sampled picks segments by text hash, so the same segments are checked on
+// every run and a rerun doesn't reshuffle the report.
+func (checker *qaChecker) sampled(text string) bool {
+	if checker.rate <= 0 {
+		return false
+	}
+	if checker.rate >= 1 {
+		return true
+	}
+	value, err := strconv.ParseUint(hashText(text)[:8], 16, 32)
+	if err != nil {
+		return false
+	}
+	return float64(value)/float64(1<<32) < checker.rate
+}
+
+// backTranslate fills Translated for each segment with the back-translation
+// of its Text, packing them into batch requests like a translation run.
+func (checker *qaChecker) backTranslate(ctx context.Context, segments []*Segment) error {
+	if len(segments) == 0 {
+		return nil
+	}
+	translator, err := checker.translators.get()
+	if err != nil {
+		return err
+	}
+	defer checker.translators.put(translator)
+	return translateSegments(ctx, translator, segments, checker.lang, checker.srcLang, segmentOptions{BatchSize: defaultBatchSize})
+}
+
+var (
+	qaTagRe         = regexp.MustCompile(`</?[A-Za-z][^>]*>|\{#[^}]*\}`)
+	qaURLRe         = regexp.MustCompile(`https?://\S+`)
+	qaPlaceholderRe = regexp.MustCompile(`__OC_I18N_\d+__`)
+	qaLatinRunRe    = regexp.MustCompile(fmt.Sprintf(`[A-Za-z][A-Za-z'’-]*(?:[ \t]+[A-Za-z][A-Za-z'’-]*){%d,}`, qaLeakWords-1))
+)
+
+// leakedEnglish returns the runs of English words in the prose of
+// translated, leaving out code, URLs, markup and the terms kept in English.
+func (checker *qaChecker) leakedEnglish(translated string) []string {
+	prose := glossaryProse(translated)
+	prose = qaTagRe.ReplaceAllString(prose, " ")
+	prose = qaURLRe.ReplaceAllString(prose, " ")
+	prose = qaPlaceholderRe.ReplaceAllString(prose, " ")
+	for _, term := range checker.keep {
+		prose = strings.ReplaceAll(prose, term, "·")
+	}
+	return qaLatinRunRe.FindAllString(prose, -1)
+}
+
+// textSimilarity is the trigram Dice similarity of the prose of a and b.
+func textSimilarity(a, b string) float64 {
+	left, right := trigrams(glossaryProse(a)), trigrams(glossaryProse(b))
+	if len(left) == 0 || len(right) == 0 {
+		return 0
+	}
+	seen := make(map[string]bool, len(left))
+	for _, gram := range left {
+		seen[gram] = true
+	}
+	shared := 0
+	for _, gram := range right {
+		if seen[gram] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(left)+len(right))
+}
+
+func hasLetters(text string) bool {
+	for _, r := range glossaryProse(text) {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return true
+		}
+	}
+	return false
+}
+
+func roundScore(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+// segmentPairs pairs the segments of a page with their translations. Only
+// the fresh ones, translated in this run, are back-translated.
+func segmentPairs(segments []Segment, fresh []*Segment) []qaPair {
+	isFresh := make(map[*Segment]bool, len(fresh))
+	for _, seg := range fresh {
+		isFresh[seg] = true
+	}
+	pairs := make([]qaPair, 0, len(segments))
+	for i := range segments {
+		seg := &segments[i]
+		pairs = append(pairs, qaPair{Source: seg.Text, Translated: seg.Translated, Reused: !isFresh[seg]})
+	}
+	return pairs
+}
+
+// blockPairs pairs source blocks with the blocks of the translated body,
+// falling back to the whole body when the blocks don't line up. Code blocks
+// are left out.
+func blockPairs(source markdownBlocks, translatedBody string) []qaPair {
+	translated := splitMarkdownBlocks(translatedBody)
+	if len(translated.Blocks) != len(source.Blocks) {
+		return []qaPair{{Source: source.join(), Translated: translatedBody}}
+	}
+	pairs := make([]qaPair, 0, len(source.Blocks))
+	for i, block := range source.Blocks {
+		if strings.HasPrefix(strings.TrimSpace(block), "```") || block == translated.Blocks[i] {
+			continue
+		}
+		pairs = append(pairs, qaPair{Source: block, Translated: translated.Blocks[i]})
+	}
+	return pairs
+}
+
+// Save merges the pages checked in this run into the report, dropping pages
+// whose source is gone, and writes it worst page first.
+func (checker *qaChecker) Save(docsRoot string) error {
+	if checker == nil || checker.path == "" {
+		return nil
+	}
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	report, err := readQAReport(checker.path)
+	if err != nil {
+		return err
+	}
+
+	files := make([]qaFileReport, 0, len(report.Files)+len(checker.files))
+	for _, file := range report.Files {
+		if _, ok := checker.files[file.Path]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(docsRoot, filepath.FromSlash(file.Path))); err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	for _, file := range checker.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Score != files[j].Score {
+			return files[i].Score > files[j].Score
+		}
+		return files[i].Path < files[j].Path
+	})
+	report.Lang, report.SrcLang, report.Files = checker.lang, checker.srcLang, files
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(checker.path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := checker.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, checker.path)
+}
+
+// readQAReport reads the report at path; a missing report is empty.
+func readQAReport(path string) (qaReport, error) {
+	var report qaReport
+	if path == "" {
+		return report, nil
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &report); err != nil {
+			return report, fmt.Errorf("qa report parse failed: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return report, err
+	}
+	return report, nil
+}
+
+// Close stops the back-translators.
+func (checker *qaChecker) Close() {
+	if checker == nil {
+		return
+	}
+	checker.translators.Close()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type backTranslatorStub struct {
+	*FakeTranslator
+	answers map[string]string
+}
+
+func (t backTranslatorStub) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return t.answers[text], nil
+}
+
+// TranslateRaw answers batches with nothing, so every text falls back to
+// Translate.
+func (t backTranslatorStub) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return "", nil
+}
+
+func TestQACheckerReport(t *testing.T) {
+	docsRoot := t.TempDir()
+	for _, name := range []string{"good.md", "bad.md"} {
+		if err := os.WriteFile(filepath.Join(docsRoot, name), []byte("# Page\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reportPath := filepath.Join(docsRoot, ".i18n", "zh-CN.qa.json")
+	// A page removed since the last run drops out of the report.
+	if err := os.MkdirAll(filepath.Dir(reportPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(reportPath, []byte(`{"lang":"zh-CN","files":[{"path":"gone.md","score":2}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := backTranslatorStub{FakeTranslator: NewFakeTranslator(), answers: map[string]string{
+		"更改后重启 Gateway 网关。":                  "Restart the Gateway after changes.",
+		"运行 `openclaw doctor` 检查配置。":         "Run `openclaw doctor` to check the config.",
+		"删除所有会话。":                            "Remove everything now.",
+		"Run the doctor before you upgrade。": "Run the doctor before you upgrade.",
+	}}
+	checker := newQAChecker(reportPath, "zh-CN", "en", 1, builtinPromptProfile("zh-CN"), nil, func() (Translator, error) {
+		return stub, nil
+	})
+	ctx := context.Background()
+	if err := checker.Check(ctx, "good.md", []qaPair{
+		{Source: "Restart the Gateway after changes.", Translated: "更改后重启 Gateway 网关。"},
+		{Source: "Run `openclaw doctor` to check the config.", Translated: "运行 `openclaw doctor` 检查配置。"},
+		{Source: "```bash\nopenclaw doctor\n```", Translated: "```bash\nopenclaw doctor\n```"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Check(ctx, "bad.md", []qaPair{
+		{Source: "Back up your sessions.", Translated: "删除所有会话。"},
+		{Source: "Run the doctor before you upgrade.", Translated: "Run the doctor before you upgrade。"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Save(docsRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report qaReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range report.Files {
+		paths = append(paths, file.Path)
+	}
+	if !slices.Equal(paths, []string{"bad.md", "good.md"}) {
+		t.Fatalf("report pages = %v", paths)
+	}
+	good, bad := report.Files[1], report.Files[0]
+	if good.Segments != 2 || good.Sampled != 2 || good.Drift != 0 || good.Leaks != 0 || len(good.Issues) != 0 {
+		t.Errorf("good page = %+v", good)
+	}
+	if bad.Leaks != 1 || len(bad.Issues) != 2 {
+		t.Fatalf("bad page = %+v", bad)
+	}
+	if leaked := bad.Issues[0].Leaked; !slices.Equal(leaked, []string{"Run the doctor before you upgrade"}) {
+		t.Errorf("leaked = %v", leaked)
+	}
+	if issue := bad.Issues[1]; issue.BackTranslation != "Remove everything now." || issue.Drift < qaDriftThreshold {
+		t.Errorf("drift issue = %+v", issue)
+	}
+}
+
+// failingTranslator fails every request.
+type failingTranslator struct {
+	*FakeTranslator
+}
+
+func (t failingTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return "", errors.New("backend down")
+}
+
+func (t failingTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return "", errors.New("backend down")
+}
+
+func TestQACheckerBatchesFreshSegments(t *testing.T) {
+	translator := NewFakeTranslator()
+	checker := newQAChecker("", "zh-CN", "en", 1, builtinPromptProfile("zh-CN"), nil, func() (Translator, error) {
+		return translator, nil
+	})
+	pairs := []qaPair{
+		{Source: "One.", Translated: "一。"},
+		{Source: "Two.", Translated: "二。"},
+		{Source: "Three.", Translated: "三。"},
+		{Source: "Reused from the translation memory.", Translated: "Reused from the translation memory。", Reused: true},
+	}
+	if err := checker.Check(context.Background(), "page.md", pairs); err != nil {
+		t.Fatal(err)
+	}
+	report := checker.files["page.md"]
+	if translator.Calls() != 1 || report.Sampled != 3 || report.Leaks != 1 {
+		t.Errorf("calls = %d, report = %+v; want one batch of the 3 fresh segments and the reused leak", translator.Calls(), report)
+	}
+}
+
+func TestQACheckerRecordsBackTranslationFailures(t *testing.T) {
+	checker := newQAChecker("", "zh-CN", "en", 1, builtinPromptProfile("zh-CN"), nil, func() (Translator, error) {
+		return failingTranslator{NewFakeTranslator()}, nil
+	})
+	pairs := []qaPair{
+		{Source: "Run the doctor before you upgrade.", Translated: "Run the doctor before you upgrade。"},
+		{Source: "Two.", Translated: "二。"},
+	}
+	if err := checker.Check(context.Background(), "page.md", pairs); err != nil {
+		t.Fatalf("Check failed the page: %v", err)
+	}
+	report := checker.files["page.md"]
+	if !strings.Contains(report.Error, "backend down") || report.Sampled != 0 || report.Leaks != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestQACheckerSamplesByText(t *testing.T) {
+	checker := &qaChecker{rate: 0.5}
+	sampled := 0
+	for _, text := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		if checker.sampled(text) != checker.sampled(text) {
+			t.Fatalf("sampling of %q is not stable", text)
+		}
+		if checker.sampled(text) {
+			sampled++
+		}
+	}
+	if sampled == 0 || sampled == 12 {
+		t.Errorf("sampled %d of 12 at rate 0.5", sampled)
+	}
+}
+
+func TestQACheckerKeepsResultsOfReusedSegments(t *testing.T) {
+	docsRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(docsRoot, "page.md"), []byte("# Page\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(docsRoot, ".i18n", "zh-CN.qa.json")
+	stub := backTranslatorStub{FakeTranslator: NewFakeTranslator(), answers: map[string]string{
+		"更改后重启 Gateway 网关。": "Restart the Gateway after changes.",
+		"删除所有会话。":           "Remove everything now.",
+		"运行医生。":             "Run the doctor.",
+	}}
+	newChecker := func() *qaChecker {
+		return newQAChecker(reportPath, "zh-CN", "en", 1, builtinPromptProfile("zh-CN"), nil, func() (Translator, error) {
+			return stub, nil
+		})
+	}
+	first := []qaPair{
+		{Source: "Restart the Gateway after changes.", Translated: "更改后重启 Gateway 网关。"},
+		{Source: "Back up your sessions.", Translated: "删除所有会话。"},
+	}
+	checker := newChecker()
+	if err := checker.Check(context.Background(), "page.md", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Save(docsRoot); err != nil {
+		t.Fatal(err)
+	}
+	want := checker.files["page.md"]
+
+	// The next run reuses both segments from the translation memory and
+	// translates a new one.
+	second := []qaPair{
+		{Source: first[0].Source, Translated: first[0].Translated, Reused: true},
+		{Source: first[1].Source, Translated: first[1].Translated, Reused: true},
+		{Source: "Run the doctor.", Translated: "运行医生。"},
+	}
+	checker = newChecker()
+	if err := checker.Check(context.Background(), "page.md", second); err != nil {
+		t.Fatal(err)
+	}
+	report := checker.files["page.md"]
+	if report.Sampled != 3 || report.Drift != roundScore(2*want.Drift/3) {
+		t.Errorf("report = %+v, want the reused drift averaged with the fresh segment", report)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].BackTranslation != "Remove everything now." || report.Issues[0].Drift != want.Issues[0].Drift {
+		t.Errorf("issues = %+v, want the reused issue %+v", report.Issues, want.Issues)
+	}
+}
@@ -8,4 +8,14 @@ type Segment struct {
 	SegmentID  string
 	Translated string
 	CacheKey   string
+	// References are similar translation memory entries sent to the model
+	// as examples of approved wording.
+	References []TMEntry
+	// Anchor is the source anchor of the heading this segment starts,
+	// written as a headingAnchor at AnchorAt (the start of the heading text).
+	Anchor   string
+	AnchorAt int
+	// Comment marks the text of a line comment in a fenced code block; its
+	// translation is folded onto one line.
+	Comment bool
 }
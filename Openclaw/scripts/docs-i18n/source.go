@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceDoc is a source page read and parsed once, then shared by every
+// target language of a run. It is read-only; processors take copies of the
+// parts they fill in (FrontData, Segments).
+type sourceDoc struct {
+	RelPath string
+	Content []byte
+	Hash    string
+	Front   string
+	Body    string
+	// Blocks is the body split into Markdown blocks (doc mode, overrides).
+	Blocks markdownBlocks
+	// HTML lists the raw HTML blocks and inline tags of the body.
+	HTML []htmlSpan
+
+	frontData map[string]any
+	frontErr  error
+	segments  []Segment
+	segErr    error
+}
+
+func loadSourceDoc(docsRoot, filePath string) (*sourceDoc, error) {
+	absPath, relPath, err := resolveDocsPath(docsRoot, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	doc := &sourceDoc{RelPath: relPath, Content: content, Hash: hashBytes(content)}
+	doc.Front, doc.Body = splitFrontMatter(string(content))
+	doc.frontData = map[string]any{}
+	if strings.TrimSpace(doc.Front) != "" {
+		if err := yaml.Unmarshal([]byte(doc.Front), &doc.frontData); err != nil {
+			doc.frontErr = fmt.Errorf("frontmatter parse failed for %s: %w", relPath, err)
+		}
+	}
+	doc.Blocks = splitMarkdownBlocks(doc.Body)
+	doc.HTML = extractHTMLSpans(doc.Body)
+	doc.segments, doc.segErr = extractSegments(doc.Body, relPath)
+	return doc, nil
+}
+
+// FrontData returns a copy of the parsed frontmatter to translate.
+func (doc *sourceDoc) FrontData() (map[string]any, error) {
+	if doc.frontErr != nil {
+		return nil, doc.frontErr
+	}
+	return cloneFrontData(doc.frontData), nil
+}
+
+// Segments returns a copy of the body's translatable segments.
+func (doc *sourceDoc) Segments() ([]Segment, error) {
+	if doc.segErr != nil {
+		return nil, doc.segErr
+	}
+	return slices.Clone(doc.segments), nil
+}
+
+// sourceCache shares parsed source pages between the jobs of a run, so a
+// page translated into several languages is parsed once. A page is dropped
+// after the last job that uses it has loaded it. A nil cache parses on every
+// load.
+type sourceCache struct {
+	mu   sync.Mutex
+	docs map[string]*cachedSource
+}
+
+type cachedSource struct {
+	once sync.Once
+	doc  *sourceDoc
+	err  error
+	left int
+}
+
+// newSourceCache expects uses[path] loads of each file path.
+func newSourceCache(uses map[string]int) *sourceCache {
+	cache := &sourceCache{docs: map[string]*cachedSource{}}
+	for path, count := range uses {
+		cache.docs[path] = &cachedSource{left: count}
+	}
+	return cache
+}
+
+func (c *sourceCache) Load(docsRoot, filePath string) (*sourceDoc, error) {
+	if c == nil {
+		return loadSourceDoc(docsRoot, filePath)
+	}
+	c.mu.Lock()
+	entry, ok := c.docs[filePath]
+	if ok {
+		entry.left--
+		if entry.left <= 0 {
+			delete(c.docs, filePath)
+		}
+	}
+	c.mu.Unlock()
+	if !ok {
+		return loadSourceDoc(docsRoot, filePath)
+	}
+	entry.once.Do(func() {
+		entry.doc, entry.err = loadSourceDoc(docsRoot, filePath)
+	})
+	return entry.doc, entry.err
+}
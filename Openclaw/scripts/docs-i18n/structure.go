@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+)
+
+// markdownShape is the part of a Markdown document a translation must not
+// change.
+type markdownShape struct {
+	Headings   []int
+	ListItems  []int
+	Tables     []string
+	CodeBlocks []string
+	Links      []string
+	HTMLTags   []string
+}
+
+func parseMarkdownShape(body string) markdownShape {
+	source := []byte(body)
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var shape markdownShape
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			shape.Headings = append(shape.Headings, node.Level)
+		case *ast.List:
+			shape.ListItems = append(shape.ListItems, node.ChildCount())
+		case *extast.Table:
+			shape.Tables = append(shape.Tables, tableShape(node))
+		case *ast.FencedCodeBlock:
+			shape.CodeBlocks = append(shape.CodeBlocks, codeBlockText(node, source))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock:
+			shape.CodeBlocks = append(shape.CodeBlocks, codeBlockText(node, source))
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			shape.Links = append(shape.Links, string(node.Destination))
+		case *ast.Image:
+			shape.Links = append(shape.Links, string(node.Destination))
+		case *ast.AutoLink:
+			shape.Links = append(shape.Links, string(node.URL(source)))
+		case *ast.HTMLBlock:
+			shape.HTMLTags = append(shape.HTMLTags, htmlTagSequence(linesText(node.Lines(), source))...)
+		case *ast.RawHTML:
+			raw := linesText(node.Segments, source)
+			if _, heading := node.Parent().(*ast.Heading); heading && anchorTagRe.MatchString(raw) {
+				// A heading anchor; the output gets one either way.
+				return ast.WalkContinue, nil
+			}
+			shape.HTMLTags = append(shape.HTMLTags, htmlTagSequence(raw)...)
+		}
+		return ast.WalkContinue, nil
+	})
+	// Translation may legitimately reorder links inside a sentence.
+	slices.Sort(shape.Links)
+	return shape
+}
+
+func tableShape(table *extast.Table) string {
+	rows := 0
+	cols := 0
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		rows++
+		cols = max(cols, row.ChildCount())
+	}
+	return fmt.Sprintf("%dx%d", rows, cols)
+}
+
+func codeBlockText(node ast.Node, source []byte) string {
+	prefix := ""
+	if fenced, ok := node.(*ast.FencedCodeBlock); ok && fenced.Info != nil {
+		prefix = string(fenced.Info.Segment.Value(source)) + "\n"
+	}
+	return prefix + linesText(node.Lines(), source)
+}
+
+func linesText(lines *text.Segments, source []byte) string {
+	var out strings.Builder
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		out.Write(segment.Value(source))
+	}
+	return out.String()
+}
+
+func htmlTagSequence(fragment string) []string {
+	var tags []string
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				tags = append(tags, "!invalid")
+			}
+			return tags
+		}
+		name, _ := tokenizer.TagName()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tags = append(tags, string(name))
+		case html.EndTagToken:
+			tags = append(tags, "/"+string(name))
+		}
+	}
+}
+
+// validateDocStructure compares the block structure of a translated body
+// with its source. Mismatches wrap errStructureMismatch; translateTaggedDoc
+// sends the request again when it gets one.
+func validateDocStructure(source, translated string) error {
+	want := parseMarkdownShape(source)
+	got := parseMarkdownShape(translated)
+	switch {
+	case !slices.Equal(want.Headings, got.Headings):
+		return fmt.Errorf("%w: heading levels %v, want %v", errStructureMismatch, got.Headings, want.Headings)
+	case !slices.Equal(want.ListItems, got.ListItems):
+		return fmt.Errorf("%w: list items %v, want %v", errStructureMismatch, got.ListItems, want.ListItems)
+	case !slices.Equal(want.Tables, got.Tables):
+		return fmt.Errorf("%w: tables %v, want %v", errStructureMismatch, got.Tables, want.Tables)
+	case !slices.Equal(want.CodeBlocks, got.CodeBlocks):
+		return fmt.Errorf("%w: code blocks changed", errStructureMismatch)
+	case !slices.Equal(want.Links, got.Links):
+		return fmt.Errorf("%w: link targets %v, want %v", errStructureMismatch, got.Links, want.Links)
+	case !slices.Equal(want.HTMLTags, got.HTMLTags):
+		return fmt.Errorf("%w: html tags %v, want %v", errStructureMismatch, got.HTMLTags, want.HTMLTags)
+	case len(extractHTMLSpans(source)) != len(extractHTMLSpans(translated)):
+		// restoreHTMLSpans pairs the spans up by position.
+		return fmt.Errorf("%w: html spans split or merged", errStructureMismatch)
+	}
+	return nil
+}
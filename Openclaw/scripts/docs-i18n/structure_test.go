@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateDocStructure(t *testing.T) {
+	source := "# Title\n\nSee [the guide](/start/guide) and <Card href=\"/x\">card</Card>.\n\n" +
+		"## Steps\n\n- one\n- two\n\n| Key | Value |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n"
+	valid := "# Titre\n\n<Card href=\"/x\">carte</Card> et [le guide](/start/guide).\n\n" +
+		"## Étapes\n\n- un\n- deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n"
+	if err := validateDocStructure(source, valid); err != nil {
+		t.Fatalf("valid translation rejected: %v", err)
+	}
+
+	cases := map[string]string{
+		"merged headings": "# Titre Étapes\n\n<Card href=\"/x\">carte</Card> [le guide](/start/guide).\n\n- un\n- deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n",
+		"dropped item":    "# Titre\n\n<Card href=\"/x\">carte</Card> [le guide](/start/guide).\n\n## Étapes\n\n- un deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n",
+		"table shape":     "# Titre\n\n<Card href=\"/x\">carte</Card> [le guide](/start/guide).\n\n## Étapes\n\n- un\n- deux\n\n| Clé |\n| --- |\n| a |\n\n```bash\necho hi\n```\n",
+		"code changed":    "# Titre\n\n<Card href=\"/x\">carte</Card> [le guide](/start/guide).\n\n## Étapes\n\n- un\n- deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho salut\n```\n",
+		"link target":     "# Titre\n\n<Card href=\"/x\">carte</Card> [le guide](/fr/start/guide).\n\n## Étapes\n\n- un\n- deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n",
+		"html tags":       "# Titre\n\nCarte et [le guide](/start/guide).\n\n## Étapes\n\n- un\n- deux\n\n| Clé | Valeur |\n| --- | --- |\n| a | b |\n\n```bash\necho hi\n```\n",
+	}
+	for name, translated := range cases {
+		err := validateDocStructure(source, translated)
+		if !errors.Is(err, errStructureMismatch) {
+			t.Errorf("%s: got %v, want structure mismatch", name, err)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// langTarget is the per-language half of a run. Every target language keeps
+// its own translation memory, glossary, prompt profile and sidecars, and gets
+// its own translators; the source pages are shared.
+type langTarget struct {
+	Lang      string
+	Config    translatorConfig
+	TM        *TranslationMemory
+	Blocks    *BlockIndex
+	Overrides *OverrideStore
+	// Check is the glossary check for the language; nil skips it.
+	Check *glossaryChecker
+	// QA is the quality-estimation pass for the language; nil skips it.
+	QA      *qaChecker
+	Process docProcessor
+	// Queue is the files to process for this language, in order.
+	Queue []string
+}
+
+// loadLangTarget loads the glossary, prompt profile and sidecars of a target
+// language from docs/.i18n. tmPath overrides the translation memory path.
+func loadLangTarget(docsRoot, lang, tmPath, glossaryMode string, cfg translatorConfig) (*langTarget, error) {
+	if tmPath == "" {
+		tmPath = filepath.Join(docsRoot, ".i18n", fmt.Sprintf("%s.tm.jsonl", lang))
+	}
+	glossary, err := LoadGlossary(filepath.Join(docsRoot, ".i18n", fmt.Sprintf("glossary.%s.json", lang)))
+	if err != nil {
+		return nil, err
+	}
+	profile, err := LoadPromptProfile(filepath.Join(docsRoot, ".i18n", "prompts", fmt.Sprintf("%s.yaml", lang)), lang)
+	if err != nil {
+		return nil, err
+	}
+	cfg.TgtLang = lang
+	cfg.Glossary = glossary
+	cfg.Prompt = profile
+	target := &langTarget{Lang: lang, Config: cfg}
+
+	switch glossaryMode {
+	case "off":
+	case "warn", "retry":
+		target.Check = newGlossaryChecker(glossary, glossaryMode == "retry")
+	default:
+		return nil, fmt.Errorf("unknown glossary check: %s", glossaryMode)
+	}
+
+	if target.TM, err = LoadTranslationMemory(tmPath); err != nil {
+		return nil, err
+	}
+	if target.Blocks, err = LoadBlockIndex(filepath.Join(docsRoot, ".i18n", fmt.Sprintf("%s.blocks.jsonl", lang))); err != nil {
+		return nil, err
+	}
+	if target.Overrides, err = LoadOverrideStore(filepath.Join(docsRoot, ".i18n", fmt.Sprintf("%s.overrides.jsonl", lang))); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// buildJobs schedules every (file, language) pair of the targets' queues,
+// file by file in the order given, so the languages of a page run close
+// together and share its parse.
+func buildJobs(docsRoot string, ordered []string, targets []*langTarget) []docJob {
+	positions := make([]map[string]int, len(targets))
+	for i, target := range targets {
+		positions[i] = make(map[string]int, len(target.Queue))
+		for index, file := range target.Queue {
+			positions[i][file] = index + 1
+		}
+	}
+	var jobs []docJob
+	for _, file := range ordered {
+		for i, target := range targets {
+			if index, ok := positions[i][file]; ok {
+				jobs = append(jobs, docJob{index: index, path: file, rel: resolveRelPath(docsRoot, file), target: target})
+			}
+		}
+	}
+	return jobs
+}
+
+// jobKey names a job in the run journal: the path of its output relative to
+// the docs root.
+func jobKey(lang, rel string) string {
+	return filepath.ToSlash(filepath.Join(lang, rel))
+}
+
+// sourceUses counts the jobs per source file, for newSourceCache.
+func sourceUses(jobs []docJob) map[string]int {
+	uses := map[string]int{}
+	for _, job := range jobs {
+		uses[job.path]++
+	}
+	return uses
+}
+
+// translatorPool starts one translator per target language on first use.
+type translatorPool map[string]Translator
+
+func (pool translatorPool) get(target *langTarget) (Translator, error) {
+	if translator, ok := pool[target.Lang]; ok {
+		return translator, nil
+	}
+	translator, err := newTranslator(target.Config)
+	if err != nil {
+		return nil, err
+	}
+	pool[target.Lang] = translator
+	return translator, nil
+}
+
+func (pool translatorPool) Close() {
+	for _, translator := range pool {
+		translator.Close()
+	}
+}
+
+// spareTranslators lends out extra translators for one language, starting
+// them on demand and keeping returned ones for the next borrower, so work
+// spread over several requests doesn't start a session each time.
+type spareTranslators struct {
+	start   func() (Translator, error)
+	mu      sync.Mutex
+	idle    []Translator
+	started []Translator
+}
+
+func newSpareTranslators(start func() (Translator, error)) *spareTranslators {
+	return &spareTranslators{start: start}
+}
+
+func (spares *spareTranslators) get() (Translator, error) {
+	spares.mu.Lock()
+	defer spares.mu.Unlock()
+	if n := len(spares.idle); n > 0 {
+		translator := spares.idle[n-1]
+		spares.idle = spares.idle[:n-1]
+		return translator, nil
+	}
+	translator, err := spares.start()
+	if err != nil {
+		return nil, err
+	}
+	spares.started = append(spares.started, translator)
+	return translator, nil
+}
+
+func (spares *spareTranslators) put(translator Translator) {
+	spares.mu.Lock()
+	defer spares.mu.Unlock()
+	spares.idle = append(spares.idle, translator)
+}
+
+// Close stops every translator started, lent out or not.
+func (spares *spareTranslators) Close() {
+	if spares == nil {
+		return
+	}
+	spares.mu.Lock()
+	defer spares.mu.Unlock()
+	for _, translator := range spares.started {
+		translator.Close()
+	}
+	spares.idle, spares.started = nil, nil
+}
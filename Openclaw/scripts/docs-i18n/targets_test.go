@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuildJobsFileByFile(t *testing.T) {
+	docsRoot := t.TempDir()
+	a, b := filepath.Join(docsRoot, "a.md"), filepath.Join(docsRoot, "b.md")
+	zh := &langTarget{Lang: "zh-CN", Queue: []string{a, b}}
+	ja := &langTarget{Lang: "ja-JP", Queue: []string{b}}
+
+	var got []string
+	for _, job := range buildJobs(docsRoot, []string{a, b}, []*langTarget{zh, ja}) {
+		got = append(got, fmt.Sprintf("%s %d", jobKey(job.target.Lang, job.rel), job.index))
+	}
+	if want := []string{"zh-CN/a.md 1", "zh-CN/b.md 2", "ja-JP/b.md 1"}; !slices.Equal(got, want) {
+		t.Fatalf("jobs = %v, want %v", got, want)
+	}
+}
+
+func TestSourceCacheSharesParse(t *testing.T) {
+	docsRoot := t.TempDir()
+	file := filepath.Join(docsRoot, "index.md")
+	if err := os.WriteFile(file, []byte("---\ntitle: Home\n---\n\n# Home\n\n<Note>\n  Read this.\n</Note>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := newSourceCache(map[string]int{file: 2})
+	first, err := cache.Load(docsRoot, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Load(docsRoot, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("the second language parsed the page again")
+	}
+	if len(cache.docs) != 0 {
+		t.Errorf("page kept after its last use: %v", cache.docs)
+	}
+	if first.RelPath != "index.md" || len(first.HTML) != 1 || len(first.segments) != 1 {
+		t.Errorf("source = %+v", first)
+	}
+
+	// Each language translates its own copy of the frontmatter.
+	front, err := first.FrontData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	front["title"] = "首页"
+	if again, _ := second.FrontData(); again["title"] != "Home" {
+		t.Errorf("frontmatter copy shared: %v", again)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type TMEntry struct {
@@ -21,14 +25,24 @@ type TMEntry struct {
 	Translated string `json:"translated"`
 	Provider   string `json:"provider"`
 	Model      string `json:"model"`
+	Profile    string `json:"profile,omitempty"`
 	SrcLang    string `json:"src_lang"`
 	TgtLang    string `json:"tgt_lang"`
 	UpdatedAt  string `json:"updated_at"`
 }
 
+// TranslationMemory is safe for concurrent use. Entries added since the last
+// Save or Checkpoint are kept in pending so a checkpoint can append them to
+// the jsonl file without rewriting it.
 type TranslationMemory struct {
+	mu      sync.RWMutex
 	path    string
 	entries map[string]TMEntry
+	pending []TMEntry
+	// rewrite is set when the file ended in a partial line or entries were
+	// pruned; the next checkpoint rewrites the file instead of appending.
+	rewrite bool
+	index   *tmIndex
 }
 
 func LoadTranslationMemory(path string) (*TranslationMemory, error) {
@@ -49,8 +63,14 @@ func LoadTranslationMemory(path string) (*TranslationMemory, error) {
 			trimmed := strings.TrimSpace(string(line))
 			if trimmed != "" {
 				var entry TMEntry
-				if err := json.Unmarshal([]byte(trimmed), &entry); err != nil {
-					return nil, fmt.Errorf("translation memory decode failed: %w", err)
+				if decodeErr := json.Unmarshal([]byte(trimmed), &entry); decodeErr != nil {
+					if errors.Is(err, io.EOF) {
+						// A checkpoint interrupted mid-write leaves a partial last line.
+						log.Printf("docs-i18n: ignoring truncated last line in %s", path)
+						tm.rewrite = true
+						break
+					}
+					return nil, fmt.Errorf("translation memory decode failed: %w", decodeErr)
 				}
 				if entry.CacheKey != "" && strings.TrimSpace(entry.Translated) != "" {
 					tm.entries[entry.CacheKey] = entry
@@ -68,7 +88,9 @@ func LoadTranslationMemory(path string) (*TranslationMemory, error) {
 }
 
 func (tm *TranslationMemory) Get(cacheKey string) (TMEntry, bool) {
+	tm.mu.RLock()
 	entry, ok := tm.entries[cacheKey]
+	tm.mu.RUnlock()
 	if !ok {
 		return TMEntry{}, false
 	}
@@ -82,13 +104,167 @@ func (tm *TranslationMemory) Put(entry TMEntry) {
 	if entry.CacheKey == "" {
 		return
 	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 	tm.entries[entry.CacheKey] = entry
+	tm.pending = append(tm.pending, entry)
+	if tm.index != nil {
+		tm.index.add(entry)
+	}
+}
+
+// Pending returns the entries added since the last Save or Checkpoint.
+func (tm *TranslationMemory) Pending() []TMEntry {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return slices.Clone(tm.pending)
+}
+
+// Entries returns a snapshot of every entry, ordered by cache key.
+func (tm *TranslationMemory) Entries() []TMEntry {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	entries := make([]TMEntry, 0, len(tm.entries))
+	for _, entry := range tm.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CacheKey < entries[j].CacheKey
+	})
+	return entries
+}
+
+// Prune drops every entry for which keep returns false and reports how many
+// were removed. The file is rewritten on the next Save.
+func (tm *TranslationMemory) Prune(keep func(TMEntry) bool) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	removed := 0
+	for key, entry := range tm.entries {
+		if !keep(entry) {
+			delete(tm.entries, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		tm.rewrite = true
+		tm.index = nil
+	}
+	return removed
+}
+
+// MoveSource carries the entries of a renamed source page over to its new
+// path: source_path and segment_id are rewritten and the cache key
+// recomputed, so lookups from the new page hit. Entries whose workflow
+// version can't be recovered are left alone. It reports how many moved.
+func (tm *TranslationMemory) MoveSource(oldRel, newRel string) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	moved := 0
+	for key, entry := range tm.entries {
+		sourcePath, ok := movedPath(entry.SourcePath, oldRel, newRel)
+		if !ok {
+			continue
+		}
+		version := entryWorkflow(entry)
+		if version == 0 {
+			continue
+		}
+		entry.SourcePath = sourcePath
+		entry.SegmentID, _ = movedPath(entry.SegmentID, oldRel, newRel)
+		namespace := cacheNamespaceVersion(version, entry.Provider, entry.Model, entry.Profile)
+		entry.CacheKey = cacheKey(namespace, entry.SrcLang, entry.TgtLang, entry.SegmentID, entry.TextHash)
+		delete(tm.entries, key)
+		tm.entries[entry.CacheKey] = entry
+		moved++
+	}
+	if moved > 0 {
+		tm.rewrite = true
+		tm.index = nil
+	}
+	return moved
+}
+
+// Checkpoint appends entries added since the last Save or Checkpoint to the
+// jsonl file. Later lines win on load, so the file stays valid even if the
+// process dies before the final Save compacts it.
+func (tm *TranslationMemory) Checkpoint() error {
+	if tm.path == "" {
+		return nil
+	}
+	tm.mu.Lock()
+	if tm.rewrite {
+		tm.mu.Unlock()
+		return tm.Save()
+	}
+	defer tm.mu.Unlock()
+	if len(tm.pending) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(tm.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(tm.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range tm.pending {
+		if err := writeTMEntry(writer, entry); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	tm.pending = nil
+	return nil
+}
+
+// StartCheckpointing checkpoints every interval until the returned stop
+// function is called. A non-positive interval disables checkpointing.
+func (tm *TranslationMemory) StartCheckpointing(interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := tm.Checkpoint(); err != nil {
+					log.Printf("docs-i18n: tm checkpoint failed: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
 }
 
 func (tm *TranslationMemory) Save() error {
 	if tm.path == "" {
 		return nil
 	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 	if err := os.MkdirAll(filepath.Dir(tm.path), 0o755); err != nil {
 		return err
 	}
@@ -106,17 +282,7 @@ func (tm *TranslationMemory) Save() error {
 
 	writer := bufio.NewWriter(file)
 	for _, key := range keys {
-		entry := tm.entries[key]
-		payload, err := json.Marshal(entry)
-		if err != nil {
-			_ = file.Close()
-			return err
-		}
-		if _, err := writer.Write(payload); err != nil {
-			_ = file.Close()
-			return err
-		}
-		if _, err := writer.WriteString("\n"); err != nil {
+		if err := writeTMEntry(writer, tm.entries[key]); err != nil {
 			_ = file.Close()
 			return err
 		}
@@ -128,5 +294,22 @@ func (tm *TranslationMemory) Save() error {
 	if err := file.Close(); err != nil {
 		return err
 	}
-	return os.Rename(tmpPath, tm.path)
+	if err := os.Rename(tmpPath, tm.path); err != nil {
+		return err
+	}
+	tm.pending = nil
+	tm.rewrite = false
+	return nil
+}
+
+func writeTMEntry(writer *bufio.Writer, entry TMEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		return err
+	}
+	_, err = writer.WriteString("\n")
+	return err
 }
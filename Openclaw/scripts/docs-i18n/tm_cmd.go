@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const tmUsage = "usage: docs-i18n tm stats|prune|export|import [flags]"
+
+// runTM implements `docs-i18n tm <command>`.
+func runTM(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(tmUsage)
+	}
+	command, args := args[0], args[1:]
+	flags := flag.NewFlagSet("tm "+command, flag.ContinueOnError)
+	var (
+		targetLang = flags.String("lang", "zh-CN", "target language")
+		sourceLang = flags.String("src", "en", "source language")
+		docsRoot   = flags.String("docs", "docs", "docs root")
+		tmPath     = flags.String("tm", "", "translation memory path")
+	)
+	var (
+		format           *string
+		dryRun           *bool
+		keepOldWorkflows *bool
+		provider         *string
+		model            *string
+		outPath          *string
+	)
+	switch command {
+	case "stats":
+		format = flags.String("format", "table", "report format (table|json)")
+	case "prune":
+		dryRun = flags.Bool("dry-run", false, "report what would be pruned without writing")
+		keepOldWorkflows = flags.Bool("keep-old-workflows", false, "keep entries from earlier workflow versions")
+		provider = flags.String("provider", "", "also drop entries from other providers")
+		model = flags.String("model", "", "also drop entries from other models")
+	case "export":
+		outPath = flags.String("out", "", "TMX output path (default stdout)")
+	case "import":
+	default:
+		return fmt.Errorf("unknown tm command: %s\n%s", command, tmUsage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	resolvedDocsRoot, err := filepath.Abs(*docsRoot)
+	if err != nil {
+		return err
+	}
+	if *tmPath == "" {
+		*tmPath = filepath.Join(resolvedDocsRoot, ".i18n", fmt.Sprintf("%s.tm.jsonl", *targetLang))
+	}
+	tm, err := LoadTranslationMemory(*tmPath)
+	if err != nil {
+		return err
+	}
+	dirs, err := languageDirs(resolvedDocsRoot, []string{*targetLang})
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "stats":
+		live, err := liveSegments(resolvedDocsRoot, *sourceLang, dirs)
+		if err != nil {
+			return err
+		}
+		return writeTMStats(stdout, collectTMStats(tm.Entries(), live), *format)
+	case "prune":
+		live, err := liveSegments(resolvedDocsRoot, *sourceLang, dirs)
+		if err != nil {
+			return err
+		}
+		removed := tm.Prune(func(entry TMEntry) bool {
+			if !live.has(entry) {
+				return false
+			}
+			if !*keepOldWorkflows && entryWorkflow(entry) != workflowVersion {
+				return false
+			}
+			if *provider != "" && entry.Provider != *provider {
+				return false
+			}
+			return *model == "" || entry.Model == *model
+		})
+		if *dryRun {
+			// Prune only changed the in-memory copy; nothing is saved.
+			fmt.Fprintf(stdout, "would prune %d entries, %d would remain\n", removed, len(tm.Entries()))
+			return nil
+		}
+		fmt.Fprintf(stdout, "pruned %d entries, %d remain\n", removed, len(tm.Entries()))
+		return tm.Save()
+	case "export":
+		out := stdout
+		if *outPath != "" {
+			file, err := os.Create(*outPath)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+		return writeTMX(out, tm.Entries(), *sourceLang)
+	case "import":
+		if flags.NArg() != 1 {
+			return errors.New("usage: docs-i18n tm import [flags] <file.tmx>")
+		}
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		doc, err := readTMX(file)
+		if err != nil {
+			return err
+		}
+		result := importTMX(tm, doc, *sourceLang, *targetLang)
+		fmt.Fprintf(stdout, "updated %d entries, %d unchanged, %d unmatched\n", result.Updated, result.Unchanged, result.Unmatched)
+		return tm.Save()
+	}
+	return nil
+}
+
+// entryWorkflow recovers the workflow version an entry was cached under by
+// recomputing its cache key, or returns 0 when no version matches.
+func entryWorkflow(entry TMEntry) int {
+	for version := workflowVersion; version > 0; version-- {
+		namespace := cacheNamespaceVersion(version, entry.Provider, entry.Model, entry.Profile)
+		if cacheKey(namespace, entry.SrcLang, entry.TgtLang, entry.SegmentID, entry.TextHash) == entry.CacheKey {
+			return version
+		}
+	}
+	return 0
+}
+
+// segmentSet holds the segment IDs and text hashes that the current docs
+// tree would look up in the translation memory.
+type segmentSet map[string]bool
+
+func (set segmentSet) has(entry TMEntry) bool {
+	return set[entry.SegmentID+"|"+entry.TextHash]
+}
+
+func liveSegments(docsRoot, srcLang string, dirs langDirs) (segmentSet, error) {
+	docs, err := listDocs(docsRoot, dirs)
+	if err != nil {
+		return nil, err
+	}
+	components, err := LoadComponentAttrs(filepath.Join(docsRoot, ".i18n", "components.json"))
+	if err != nil {
+		return nil, err
+	}
+	// HTML text and component attributes are found by replaying their
+	// translation with the fake backend into a scratch memory.
+	scratch, err := LoadTranslationMemory("")
+	if err != nil {
+		return nil, err
+	}
+	set := segmentSet{}
+	add := func(segmentID, text string) {
+		set[segmentID+"|"+hashText(text)] = true
+	}
+	for _, rel := range docs {
+		relPath := filepath.ToSlash(rel)
+		content, err := os.ReadFile(filepath.Join(docsRoot, rel))
+		if err != nil {
+			return nil, err
+		}
+		frontMatter, body := splitFrontMatter(string(content))
+		frontData := map[string]any{}
+		if err := yaml.Unmarshal([]byte(frontMatter), &frontData); err == nil {
+			if summary, ok := frontData["summary"].(string); ok {
+				add(relPath+":frontmatter:summary", summary)
+			}
+			if title, ok := frontData["title"].(string); ok {
+				add(relPath+":frontmatter:title", title)
+			}
+			if readWhen, ok := frontData["read_when"].([]any); ok {
+				for idx, item := range readWhen {
+					if text, ok := item.(string); ok {
+						add(fmt.Sprintf("%s:frontmatter:read_when:%d", relPath, idx), text)
+					}
+				}
+			}
+		}
+		segments, err := extractSegments(body, relPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", relPath, err)
+		}
+		// Comments are live whichever code blocks a run translates them in.
+		for _, seg := range withCodeComments(segments, body, relPath, allCodeComments()) {
+			set[seg.SegmentID+"|"+seg.TextHash] = true
+		}
+		if _, err := translateHTMLBlocks(context.Background(), NewFakeTranslator(), scratch, relPath, body, srcLang, srcLang, components); err != nil {
+			return nil, fmt.Errorf("%s: %w", relPath, err)
+		}
+	}
+	for _, entry := range scratch.Entries() {
+		set[entry.SegmentID+"|"+entry.TextHash] = true
+	}
+	labels, err := sourceNavLabels(docsRoot, srcLang)
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		add(navSegmentID(label), label)
+	}
+	return set, nil
+}
+
+type tmCount struct {
+	Key         string `json:"key"`
+	Entries     int    `json:"entries"`
+	Unreachable int    `json:"unreachable"`
+}
+
+type tmStats struct {
+	Entries     int       `json:"entries"`
+	Unreachable int       `json:"unreachable"`
+	Namespaces  []tmCount `json:"namespaces"`
+	Models      []tmCount `json:"models"`
+	Sources     []tmCount `json:"sources"`
+}
+
+func collectTMStats(entries []TMEntry, live segmentSet) tmStats {
+	namespaces := map[string]*tmCount{}
+	models := map[string]*tmCount{}
+	sources := map[string]*tmCount{}
+	bump := func(counts map[string]*tmCount, key string, unreachable bool) {
+		count, ok := counts[key]
+		if !ok {
+			count = &tmCount{Key: key}
+			counts[key] = count
+		}
+		count.Entries++
+		if unreachable {
+			count.Unreachable++
+		}
+	}
+
+	stats := tmStats{Entries: len(entries)}
+	for _, entry := range entries {
+		unreachable := !live.has(entry)
+		if unreachable {
+			stats.Unreachable++
+		}
+		namespace := "wf=?|provider=" + entry.Provider + "|model=" + entry.Model
+		if version := entryWorkflow(entry); version > 0 {
+			namespace = cacheNamespaceVersion(version, entry.Provider, entry.Model, entry.Profile)
+		}
+		// Frontmatter entries use "<path>:frontmatter:<field>" as their source.
+		source, _, _ := strings.Cut(entry.SourcePath, ":")
+		bump(namespaces, namespace, unreachable)
+		bump(models, entry.Provider+"/"+entry.Model, unreachable)
+		bump(sources, source, unreachable)
+	}
+	stats.Namespaces = sortedCounts(namespaces)
+	stats.Models = sortedCounts(models)
+	stats.Sources = sortedCounts(sources)
+	return stats
+}
+
+func sortedCounts(counts map[string]*tmCount) []tmCount {
+	out := make([]tmCount, 0, len(counts))
+	for _, count := range counts {
+		out = append(out, *count)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func writeTMStats(w io.Writer, stats tmStats, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	case "table":
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	fmt.Fprintf(w, "entries: %d (%d unreachable from the current docs)\n", stats.Entries, stats.Unreachable)
+	for _, section := range []struct {
+		title  string
+		counts []tmCount
+	}{
+		{"NAMESPACE", stats.Namespaces},
+		{"MODEL", stats.Models},
+		{"SOURCE", stats.Sources},
+	} {
+		fmt.Fprintln(w)
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(table, "%s\tENTRIES\tUNREACHABLE\n", section.title)
+		for _, count := range section.counts {
+			fmt.Fprintf(table, "%s\t%d\t%d\n", count.Key, count.Entries, count.Unreachable)
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type tmxImportResult struct {
+	Updated   int
+	Unchanged int
+	Unmatched int
+}
+
+// importTMX applies the target segments of a TMX file to the translation
+// memory. Units are matched by tuid (the cache key written by export) and
+// otherwise by source text, which updates every entry with that text.
+func importTMX(tm *TranslationMemory, doc tmxDocument, srcLang, tgtLang string) tmxImportResult {
+	byText := map[string][]TMEntry{}
+	for _, entry := range tm.Entries() {
+		if strings.EqualFold(entry.TgtLang, tgtLang) {
+			byText[entry.TextHash] = append(byText[entry.TextHash], entry)
+		}
+	}
+
+	var result tmxImportResult
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, unit := range doc.Units {
+		source, okSource := unit.segment(srcLang)
+		translated, okTarget := unit.segment(tgtLang)
+		if !okSource || !okTarget || strings.TrimSpace(translated) == "" {
+			result.Unmatched++
+			continue
+		}
+		matches := byText[hashText(source)]
+		if entry, ok := tm.Get(unit.TUID); ok {
+			matches = []TMEntry{entry}
+		}
+		if len(matches) == 0 {
+			result.Unmatched++
+			continue
+		}
+		for _, entry := range matches {
+			if entry.Translated == translated {
+				result.Unchanged++
+				continue
+			}
+			entry.Translated = translated
+			entry.UpdatedAt = now
+			tm.Put(entry)
+			result.Updated++
+		}
+	}
+	return result
+}
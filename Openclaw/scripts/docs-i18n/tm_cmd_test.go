@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// translatedFixtureTM runs segment mode over the fixtures and returns the
+// docs root and the saved translation memory.
+func translatedFixtureTM(t *testing.T) (string, *TranslationMemory) {
+	t.Helper()
+	docsRoot := copyFixtureDocs(t)
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range fixtureFiles(t, docsRoot) {
+		if _, err := processFile(context.Background(), NewFakeTranslator(), tm, nil, nil, docsRoot, file, "en", "zh-CN", segmentOptions{BatchSize: defaultBatchSize}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return docsRoot, tm
+}
+
+func TestTMPruneDropsUnreachableEntries(t *testing.T) {
+	docsRoot, tm := translatedFixtureTM(t)
+	live := len(tm.Entries())
+	for _, entry := range []TMEntry{
+		{SegmentID: "deleted.md:0123456789abcdef", SourcePath: "deleted.md", Text: "Gone"},
+		{SegmentID: tm.Entries()[0].SegmentID, SourcePath: "index.md", Text: tm.Entries()[0].Text},
+	} {
+		entry.TextHash = hashText(entry.Text)
+		entry.Translated = "旧"
+		entry.Provider, entry.Model, entry.SrcLang, entry.TgtLang = "fake", "pseudo", "en", "zh-CN"
+		entry.CacheKey = cacheKey(cacheNamespaceVersion(workflowVersion-1, entry.Provider, entry.Model, ""), "en", "zh-CN", entry.SegmentID, entry.TextHash)
+		tm.Put(entry)
+	}
+	if err := tm.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runTM([]string{"stats", "-docs", docsRoot}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "deleted.md") || !strings.Contains(out.String(), cacheNamespaceVersion(workflowVersion-1, "fake", "pseudo", "")) {
+		t.Fatalf("stats missing the stale entries:\n%s", out.String())
+	}
+	out.Reset()
+	if err := runTM([]string{"prune", "-docs", docsRoot, "-dry-run"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("would prune 2 entries, %d would remain\n", live); out.String() != want {
+		t.Fatalf("dry run printed %q, want %q", out.String(), want)
+	}
+	if err := runTM([]string{"prune", "-docs", docsRoot}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(pruned.Entries()); got != live {
+		t.Fatalf("%d entries after prune, want %d", got, live)
+	}
+}
+
+func TestTMXRoundTrip(t *testing.T) {
+	docsRoot, tm := translatedFixtureTM(t)
+	tmxPath := filepath.Join(t.TempDir(), "zh-CN.tmx")
+	if err := runTM([]string{"export", "-docs", docsRoot, "-out", tmxPath}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(tmxPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := tm.Entries()[0]
+	if !strings.Contains(string(data), `<tuv xml:lang="zh-CN">`) || !strings.Contains(string(data), entry.CacheKey) {
+		t.Fatalf("export is not TMX with cache keys:\n%.600s", data)
+	}
+
+	// A reviewer corrects one unit in a CAT tool.
+	corrected := strings.Replace(string(data), ">"+escapeXML(t, entry.Translated)+"<", ">人工译文<", 1)
+	if err := os.WriteFile(tmxPath, []byte(corrected), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runTM([]string{"import", "-docs", docsRoot, tmxPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "updated 1 entries") {
+		t.Fatalf("import reported %q", out.String())
+	}
+	reloaded, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Get(entry.CacheKey); got.Translated != "人工译文" {
+		t.Fatalf("imported translation = %q", got.Translated)
+	}
+}
+
+func escapeXML(t *testing.T, text string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := xml.EscapeText(&out, []byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// fuzzyMinScore is the trigram Dice similarity below which a TM entry is
+	// not worth showing the model.
+	fuzzyMinScore = 0.6
+	// fuzzyMaxBatchReferences caps the references sent with a batch request.
+	fuzzyMaxBatchReferences = 10
+)
+
+// TMMatch is a translation memory entry similar to a lookup text.
+type TMMatch struct {
+	Entry TMEntry
+	Score float64
+}
+
+// tmIndex is the lookup side of the translation memory: entries by language
+// pair and normalized text hash, and by character trigram for fuzzy search.
+// It is built on first use and kept up to date by Put.
+type tmIndex struct {
+	byText   map[string][]string
+	grams    map[string][]string
+	postings map[string][]string
+}
+
+func newTMIndex(entries map[string]TMEntry) *tmIndex {
+	index := &tmIndex{byText: map[string][]string{}, grams: map[string][]string{}, postings: map[string][]string{}}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		index.add(entries[key])
+	}
+	return index
+}
+
+func (index *tmIndex) add(entry TMEntry) {
+	if _, ok := index.grams[entry.CacheKey]; ok {
+		// The cache key covers the text, so a replaced entry indexes the same.
+		return
+	}
+	textKey := languagePairKey(entry.SrcLang, entry.TgtLang, entry.TextHash)
+	index.byText[textKey] = append(index.byText[textKey], entry.CacheKey)
+	grams := trigrams(entry.Text)
+	index.grams[entry.CacheKey] = grams
+	for _, gram := range grams {
+		index.postings[gram] = append(index.postings[gram], entry.CacheKey)
+	}
+}
+
+func languagePairKey(srcLang, tgtLang, textHash string) string {
+	return srcLang + "|" + tgtLang + "|" + textHash
+}
+
+// lookupIndex returns the index, building it on first use. Callers must not
+// hold tm.mu.
+func (tm *TranslationMemory) lookupIndex() *tmIndex {
+	tm.mu.RLock()
+	index := tm.index
+	tm.mu.RUnlock()
+	if index != nil {
+		return index
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.index == nil {
+		tm.index = newTMIndex(tm.entries)
+	}
+	return tm.index
+}
+
+// FindText returns an entry for the same normalized text cached under
+// namespace, regardless of the page it came from. The most recently updated
+// entry wins.
+func (tm *TranslationMemory) FindText(namespace, srcLang, tgtLang, textHash string) (TMEntry, bool) {
+	index := tm.lookupIndex()
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	var best TMEntry
+	found := false
+	for _, key := range index.byText[languagePairKey(srcLang, tgtLang, textHash)] {
+		entry := tm.entries[key]
+		if strings.TrimSpace(entry.Translated) == "" {
+			continue
+		}
+		if cacheKey(namespace, srcLang, tgtLang, entry.SegmentID, entry.TextHash) != entry.CacheKey {
+			continue
+		}
+		if !found || entry.UpdatedAt > best.UpdatedAt {
+			best = entry
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Similar returns up to limit entries whose text is close to text by trigram
+// similarity, best first. Entries with the identical normalized text are left
+// to FindText, and each distinct source text is returned once.
+func (tm *TranslationMemory) Similar(text, srcLang, tgtLang string, limit int) []TMMatch {
+	if limit <= 0 {
+		return nil
+	}
+	query := trigrams(text)
+	if len(query) == 0 {
+		return nil
+	}
+	textHash := hashText(text)
+	index := tm.lookupIndex()
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	// Reaching fuzzyMinScore takes at least minShared of the query's
+	// trigrams, so a match has one of the len(query)-minShared+1 rarest.
+	// Candidates come from those postings only; the long postings of common
+	// trigrams such as " th" are never walked.
+	minShared := int(math.Ceil(fuzzyMinScore*float64(len(query))/(2-fuzzyMinScore) - 1e-9))
+	rarest := slices.Clone(query)
+	sort.SliceStable(rarest, func(i, j int) bool {
+		return len(index.postings[rarest[i]]) < len(index.postings[rarest[j]])
+	})
+	candidates := map[string]bool{}
+	for _, gram := range rarest[:len(query)-max(minShared, 1)+1] {
+		for _, key := range index.postings[gram] {
+			candidates[key] = true
+		}
+	}
+	inQuery := make(map[string]bool, len(query))
+	for _, gram := range query {
+		inQuery[gram] = true
+	}
+
+	best := map[string]TMMatch{}
+	for key := range candidates {
+		grams := index.grams[key]
+		if 2*float64(min(len(query), len(grams)))/float64(len(query)+len(grams)) < fuzzyMinScore {
+			// Too short or too long to reach the score.
+			continue
+		}
+		count := 0
+		for _, gram := range grams {
+			if inQuery[gram] {
+				count++
+			}
+		}
+		score := 2 * float64(count) / float64(len(query)+len(grams))
+		if score < fuzzyMinScore {
+			continue
+		}
+		entry, ok := tm.entries[key]
+		if !ok || entry.TextHash == textHash || entry.SrcLang != srcLang || entry.TgtLang != tgtLang || strings.TrimSpace(entry.Translated) == "" {
+			continue
+		}
+		current, seen := best[entry.TextHash]
+		if !seen || entry.UpdatedAt > current.Entry.UpdatedAt {
+			best[entry.TextHash] = TMMatch{Entry: entry, Score: score}
+		}
+	}
+
+	matches := make([]TMMatch, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Entry.CacheKey < matches[j].Entry.CacheKey
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// trigrams returns the distinct character trigrams of the normalized,
+// lower-cased text, padded so short words still contribute.
+func trigrams(text string) []string {
+	normalized := normalizeText(strings.ToLower(text))
+	if normalized == "" {
+		return nil
+	}
+	runes := []rune(" " + normalized + " ")
+	seen := map[string]bool{}
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if seen[gram] {
+			continue
+		}
+		seen[gram] = true
+		grams = append(grams, gram)
+	}
+	return grams
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTranslationMemorySimilar(t *testing.T) {
+	tm, err := LoadTranslationMemory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range []string{
+		"Restart the Gateway after changing the config.",
+		"Restart the Gateway after changing the configuration.",
+		"Pairing codes expire after one hour.",
+	} {
+		tm.Put(TMEntry{CacheKey: string(rune('a' + i)), Text: text, TextHash: hashText(text), Translated: "译文", SrcLang: "en", TgtLang: "zh-CN"})
+	}
+	matches := tm.Similar("Restart the Gateway after changing the configuration.", "en", "zh-CN", 3)
+	if len(matches) != 1 || matches[0].Entry.CacheKey != "a" {
+		t.Fatalf("Similar = %+v, want only the near match (exact text is left to FindText)", matches)
+	}
+	if matches[0].Score < fuzzyMinScore || matches[0].Score >= 1 {
+		t.Fatalf("score = %f", matches[0].Score)
+	}
+	if got := tm.Similar("Restart the Gateway after changing the configuration.", "en", "ja-JP", 3); len(got) != 0 {
+		t.Fatalf("matched across language pairs: %+v", got)
+	}
+}
+
+func TestTranslationMemorySimilarFindsEveryMatch(t *testing.T) {
+	tm, err := LoadTranslationMemory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := []string{"the", "Gateway", "restart", "config", "channel", "token", "pairing", "after", "a", "one"}
+	var texts []string
+	for i := 0; i < 300; i++ {
+		var text []string
+		for j := 0; j < 2+i%5; j++ {
+			text = append(text, words[(i*7+j*3+j*j)%len(words)])
+		}
+		texts = append(texts, strings.Join(text, " "))
+		tm.Put(TMEntry{CacheKey: fmt.Sprintf("%03d", i), Text: texts[i], TextHash: hashText(texts[i]), Translated: "译文", SrcLang: "en", TgtLang: "zh-CN"})
+	}
+	for _, query := range texts[:40] {
+		// Score every entry, as Similar did before it skipped common trigrams.
+		want := map[string]bool{}
+		queryGrams := trigrams(query)
+		for _, text := range texts {
+			shared := 0
+			for _, gram := range trigrams(text) {
+				if slices.Contains(queryGrams, gram) {
+					shared++
+				}
+			}
+			if hashText(text) != hashText(query) && 2*float64(shared)/float64(len(queryGrams)+len(trigrams(text))) >= fuzzyMinScore {
+				want[hashText(text)] = true
+			}
+		}
+		got := map[string]bool{}
+		for _, match := range tm.Similar(query, "en", "zh-CN", len(texts)) {
+			got[match.Entry.TextHash] = true
+		}
+		if !maps.Equal(got, want) {
+			t.Fatalf("Similar(%q) found %d texts, want %d", query, len(got), len(want))
+		}
+	}
+}
+
+// referenceRecorder records the reference block sent with each request.
+type referenceRecorder struct {
+	*FakeTranslator
+	mu         sync.Mutex
+	references []string
+}
+
+func (t *referenceRecorder) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	t.record(hints)
+	return t.FakeTranslator.Translate(ctx, text, srcLang, tgtLang, hints)
+}
+
+func (t *referenceRecorder) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	t.record(hints)
+	return t.FakeTranslator.TranslateRaw(ctx, text, srcLang, tgtLang, hints)
+}
+
+func (t *referenceRecorder) record(hints promptHints) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.references = append(t.references, hints.block())
+}
+
+func TestSegmentModeReusesTextAndSendsReferences(t *testing.T) {
+	docsRoot := copyFixtureDocs(t)
+	tm, err := LoadTranslationMemory(filepath.Join(docsRoot, ".i18n", "zh-CN.tm.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace := cacheNamespace("fake", "pseudo", "")
+	put := func(relPath, text, translated string) {
+		textHash := hashText(text)
+		id := segmentID(relPath, textHash)
+		tm.Put(TMEntry{
+			CacheKey:   cacheKey(namespace, "en", "zh-CN", id, textHash),
+			SegmentID:  id,
+			SourcePath: relPath,
+			TextHash:   textHash,
+			Text:       text,
+			Translated: translated,
+			Provider:   "fake",
+			Model:      "pseudo",
+			SrcLang:    "en",
+			TgtLang:    "zh-CN",
+		})
+	}
+	// The heading moved here from another page; the list item changed a word.
+	put("start/old.md", "Quick start", "快速开始")
+	put("start/old.md", "Send a message from your computer.", "从你的电脑发送一条消息。")
+
+	translator := &referenceRecorder{FakeTranslator: NewFakeTranslator()}
+	file := filepath.Join(docsRoot, "index.md")
+	if _, err := processFile(context.Background(), translator, tm, nil, nil, docsRoot, file, "en", "zh-CN", segmentOptions{BatchSize: defaultBatchSize, References: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(docsRoot, "zh-CN", "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), `## <a id="quick-start" />快速开始`) {
+		t.Errorf("moved heading was retranslated:\n%s", output)
+	}
+	textHash := hashText("Quick start")
+	if _, ok := tm.Get(cacheKey(namespace, "en", "zh-CN", segmentID("index.md", textHash), textHash)); !ok {
+		t.Error("reused translation was not stored under the new page")
+	}
+	sent := strings.Join(translator.references, "\n")
+	if !strings.Contains(sent, "从你的电脑发送一条消息。") {
+		t.Errorf("near match was not sent as a reference; sent:\n%s", sent)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranslationMemoryCheckpointAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zh-CN.tm.jsonl")
+	tm, err := LoadTranslationMemory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm.Put(TMEntry{CacheKey: "a", Text: "Hello", Translated: "你好"})
+	if err := tm.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	tm.Put(TMEntry{CacheKey: "b", Text: "World", Translated: "世界"})
+	tm.Put(TMEntry{CacheKey: "a", Text: "Hello", Translated: "您好"})
+	if err := tm.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Fatalf("checkpoint wrote %d lines, want 3 appended lines", lines)
+	}
+
+	// Simulate a crash in the middle of the next append.
+	if err := os.WriteFile(path, append(data, []byte(`{"cache_key":"c","tra`)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadTranslationMemory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := reloaded.Get("a"); !ok || entry.Translated != "您好" {
+		t.Fatalf("Get(a) = %+v, %t; want the later line to win", entry, ok)
+	}
+	if _, ok := reloaded.Get("b"); !ok {
+		t.Fatal("Get(b) missing after reload")
+	}
+
+	reloaded.Put(TMEntry{CacheKey: "d", Text: "Bye", Translated: "再见"})
+	if err := reloaded.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	compacted, err := LoadTranslationMemory(path)
+	if err != nil {
+		t.Fatalf("reload after recovering from a truncated line: %v", err)
+	}
+	for _, key := range []string{"a", "b", "d"} {
+		if _, ok := compacted.Get(key); !ok {
+			t.Errorf("Get(%s) missing after compaction", key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tmxDateLayout = "20060102T150405Z"
+
+// TMX 1.4 document, limited to what docs-i18n reads and writes: one source
+// and one target variant per unit, plain-text segments, and the TMEntry
+// metadata carried as x- props.
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tmxUnit struct {
+	TUID       string       `xml:"tuid,attr,omitempty"`
+	ChangeDate string       `xml:"changedate,attr,omitempty"`
+	Props      []tmxProp    `xml:"prop"`
+	Variants   []tmxVariant `xml:"tuv"`
+}
+
+type tmxProp struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tmxVariant struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Seg  string `xml:"seg"`
+}
+
+func writeTMX(w io.Writer, entries []TMEntry, srcLang string) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "docs-i18n",
+			CreationToolVersion: strconv.Itoa(workflowVersion),
+			SegType:             "block",
+			OTMF:                "docs-i18n-jsonl",
+			AdminLang:           "en",
+			SrcLang:             srcLang,
+			DataType:            "markdown",
+		},
+	}
+	for _, entry := range entries {
+		unit := tmxUnit{
+			TUID: entry.CacheKey,
+			Props: []tmxProp{
+				{Type: "x-segment-id", Value: entry.SegmentID},
+				{Type: "x-source-path", Value: entry.SourcePath},
+				{Type: "x-provider", Value: entry.Provider},
+				{Type: "x-model", Value: entry.Model},
+			},
+			Variants: []tmxVariant{
+				{Lang: entry.SrcLang, Seg: entry.Text},
+				{Lang: entry.TgtLang, Seg: entry.Translated},
+			},
+		}
+		if updated, err := time.Parse(time.RFC3339, entry.UpdatedAt); err == nil {
+			unit.ChangeDate = updated.UTC().Format(tmxDateLayout)
+		}
+		doc.Units = append(doc.Units, unit)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func readTMX(r io.Reader) (tmxDocument, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return tmxDocument{}, fmt.Errorf("tmx decode failed: %w", err)
+	}
+	return doc, nil
+}
+
+// segment returns the segment for lang, matching case-insensitively and
+// falling back to the primary subtag (zh-cn, zh).
+func (unit tmxUnit) segment(lang string) (string, bool) {
+	for _, variant := range unit.Variants {
+		if strings.EqualFold(variant.Lang, lang) {
+			return variant.Seg, true
+		}
+	}
+	primary, _, _ := strings.Cut(lang, "-")
+	for _, variant := range unit.Variants {
+		if strings.EqualFold(variant.Lang, primary) {
+			return variant.Seg, true
+		}
+	}
+	return "", false
+}
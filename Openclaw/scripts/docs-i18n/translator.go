@@ -18,45 +18,129 @@ const (
 
 var errEmptyTranslation = errors.New("empty translation")
 
+const defaultPiModel = "claude-opus-4-6"
+
+// Translator is implemented by every translation backend.
+type Translator interface {
+	Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error)
+	TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error)
+	Provider() string
+	Model() string
+	// Profile identifies a non-default prompt profile; it is empty for the
+	// built-in prompts.
+	Profile() string
+	Close()
+}
+
+type translatorConfig struct {
+	Provider string
+	Endpoint string
+	Model    string
+	Thinking string
+	SrcLang  string
+	TgtLang  string
+	Glossary []GlossaryEntry
+	Prompt   promptProfile
+	// SystemPrompt replaces the translation prompt built from the fields
+	// above, for requests that aren't translations of the docs.
+	SystemPrompt string
+	// Temperature is the sampling temperature for the openai provider; nil
+	// keeps the server's default.
+	Temperature *float64
+}
+
+func newTranslator(cfg translatorConfig) (Translator, error) {
+	systemPrompt := cfg.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = translationPrompt(cfg.SrcLang, cfg.Prompt, cfg.Glossary)
+	}
+	profile := profileHash(cfg.Prompt, cfg.TgtLang)
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", "pi":
+		translator, err := NewPiTranslator(systemPrompt, profile, cfg.Model, cfg.Thinking)
+		if err != nil {
+			return nil, err
+		}
+		return translator, nil
+	case "openai":
+		translator, err := NewOpenAITranslator(systemPrompt, profile, cfg.Endpoint, cfg.Model, cfg.Temperature)
+		if err != nil {
+			return nil, err
+		}
+		return translator, nil
+	case "fake":
+		return NewFakeTranslator(), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
+	}
+}
+
+type promptFunc func(ctx context.Context, message string) (string, error)
+
 type PiTranslator struct {
-	client *pi.OneShotClient
+	client  *pi.OneShotClient
+	model   string
+	profile string
 }
 
-func NewPiTranslator(srcLang, tgtLang string, glossary []GlossaryEntry, thinking string) (*PiTranslator, error) {
+func NewPiTranslator(systemPrompt, profile, model, thinking string) (*PiTranslator, error) {
+	if strings.TrimSpace(model) == "" {
+		model = defaultPiModel
+	}
 	options := pi.DefaultOneShotOptions()
 	options.AppName = "openclaw-docs-i18n"
 	options.WorkDir = "/tmp"
 	options.Mode = pi.ModeDragons
 	options.Dragons = pi.DragonsOptions{
 		Provider: "anthropic",
-		Model:    modelVersion,
+		Model:    model,
 		Thinking: normalizeThinking(thinking),
 	}
-	options.SystemPrompt = translationPrompt(srcLang, tgtLang, glossary)
+	options.SystemPrompt = systemPrompt
 	client, err := pi.StartOneShot(options)
 	if err != nil {
 		return nil, err
 	}
-	return &PiTranslator{client: client}, nil
+	return &PiTranslator{client: client, model: model, profile: profile}, nil
 }
 
-func (t *PiTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string) (string, error) {
-	return t.translate(ctx, text, t.translateMasked)
+func (t *PiTranslator) Translate(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateMasked(ctx, t.prompt, hints, core)
+	})
 }
 
-func (t *PiTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string) (string, error) {
-	return t.translate(ctx, text, t.translateRaw)
+func (t *PiTranslator) TranslateRaw(ctx context.Context, text, srcLang, tgtLang string, hints promptHints) (string, error) {
+	return translateText(ctx, text, func(ctx context.Context, core string) (string, error) {
+		return translateRaw(ctx, t.prompt, hints, core)
+	})
 }
 
-func (t *PiTranslator) translate(ctx context.Context, text string, run func(context.Context, string) (string, error)) (string, error) {
+func (t *PiTranslator) Provider() string {
+	return "pi"
+}
+
+func (t *PiTranslator) Model() string {
+	return t.model
+}
+
+func (t *PiTranslator) Profile() string {
+	return t.profile
+}
+
+func (t *PiTranslator) prompt(ctx context.Context, message string) (string, error) {
 	if t.client == nil {
 		return "", errors.New("pi client unavailable")
 	}
+	return runPrompt(ctx, t.client, message)
+}
+
+func translateText(ctx context.Context, text string, run func(context.Context, string) (string, error)) (string, error) {
 	prefix, core, suffix := splitWhitespace(text)
 	if core == "" {
 		return text, nil
 	}
-	translated, err := t.translateWithRetry(ctx, func(ctx context.Context) (string, error) {
+	translated, err := translateWithRetry(ctx, func(ctx context.Context) (string, error) {
 		return run(ctx, core)
 	})
 	if err != nil {
@@ -65,19 +149,23 @@ func (t *PiTranslator) translate(ctx context.Context, text string, run func(cont
 	return prefix + translated + suffix, nil
 }
 
-func (t *PiTranslator) translateWithRetry(ctx context.Context, run func(context.Context) (string, error)) (string, error) {
+func translateWithRetry(ctx context.Context, run func(context.Context) (string, error)) (string, error) {
 	var lastErr error
 	for attempt := 0; attempt < translateMaxAttempts; attempt++ {
 		translated, err := run(ctx)
 		if err == nil {
 			return translated, nil
 		}
+		if isValidationError(err) {
+			emitFileEvent(ctx, runEvent{Event: "validation_failure", Attempt: attempt + 1, Error: err.Error()})
+		}
 		if !isRetryableTranslateError(err) {
 			return "", err
 		}
 		lastErr = err
 		if attempt+1 < translateMaxAttempts {
 			delay := translateBaseDelay * time.Duration(attempt+1)
+			emitFileEvent(ctx, runEvent{Event: "retry", Attempt: attempt + 2, DelayMS: delay.Milliseconds(), Error: err.Error()})
 			if err := sleepWithContext(ctx, delay); err != nil {
 				return "", err
 			}
@@ -86,16 +174,16 @@ func (t *PiTranslator) translateWithRetry(ctx context.Context, run func(context.
 	return "", lastErr
 }
 
-func (t *PiTranslator) translateMasked(ctx context.Context, core string) (string, error) {
+func translateMasked(ctx context.Context, prompt promptFunc, hints promptHints, core string) (string, error) {
 	state := NewPlaceholderState(core)
 	placeholders := make([]string, 0, 8)
 	mapping := map[string]string{}
 	masked := maskMarkdown(core, state.Next, &placeholders, mapping)
-	resText, err := runPrompt(ctx, t.client, masked)
+	resText, err := prompt(ctx, hints.block()+masked)
 	if err != nil {
 		return "", err
 	}
-	translated := strings.TrimSpace(resText)
+	translated := strings.TrimSpace(stripContextBlock(resText))
 	if translated == "" {
 		return "", errEmptyTranslation
 	}
@@ -105,23 +193,43 @@ func (t *PiTranslator) translateMasked(ctx context.Context, core string) (string
 	return unmaskMarkdown(translated, placeholders, mapping), nil
 }
 
-func (t *PiTranslator) translateRaw(ctx context.Context, core string) (string, error) {
-	resText, err := runPrompt(ctx, t.client, core)
+func translateRaw(ctx context.Context, prompt promptFunc, hints promptHints, core string) (string, error) {
+	resText, err := prompt(ctx, hints.block()+core)
 	if err != nil {
 		return "", err
 	}
-	translated := strings.TrimSpace(resText)
+	translated := strings.TrimSpace(stripContextBlock(resText))
 	if translated == "" {
 		return "", errEmptyTranslation
 	}
 	return translated, nil
 }
 
+// promptHints is what a request sends ahead of its text in a <context>
+// block. The zero value sends nothing.
+type promptHints struct {
+	// References are translations of similar text from the translation
+	// memory.
+	References []TMEntry
+	// Corrections are terminology fixes, such as the glossary violations of
+	// an earlier attempt.
+	Corrections []string
+}
+
+func (hints promptHints) block() string {
+	refs := referenceContext{Terminology: hints.Corrections}
+	for _, entry := range hints.References {
+		refs.Source = append(refs.Source, strings.TrimSpace(entry.Text))
+		refs.Translation = append(refs.Translation, strings.TrimSpace(entry.Translated))
+	}
+	return formatContextBlock(refs)
+}
+
 func isRetryableTranslateError(err error) bool {
 	if err == nil {
 		return false
 	}
-	if errors.Is(err, errEmptyTranslation) {
+	if errors.Is(err, errEmptyTranslation) || errors.Is(err, errUpstreamUnavailable) {
 		return true
 	}
 	message := strings.ToLower(err.Error())
@@ -154,6 +262,7 @@ type agentMessage struct {
 	Content      json.RawMessage `json:"content"`
 	StopReason   string          `json:"stopReason,omitempty"`
 	ErrorMessage string          `json:"errorMessage,omitempty"`
+	Usage        *pi.Usage       `json:"usage,omitempty"`
 }
 
 type contentBlock struct {
@@ -178,16 +287,21 @@ func runPrompt(ctx context.Context, client *pi.OneShotClient, message string) (s
 				return "", errors.New("event stream closed")
 			}
 			if event.Type == "agent_end" {
-				return extractTranslationResult(event.Raw)
+				text, usage, err := extractTranslationResult(event.Raw)
+				recordUsage(ctx, piUsage(usage))
+				return text, err
 			}
 		}
 	}
 }
 
-func extractTranslationResult(raw json.RawMessage) (string, error) {
+// extractTranslationResult returns the text of the last assistant message
+// in an agent_end payload and the usage it reported, which is billed even
+// when the message is an error.
+func extractTranslationResult(raw json.RawMessage) (string, *pi.Usage, error) {
 	var payload agentEndPayload
 	if err := json.Unmarshal(raw, &payload); err != nil {
-		return "", err
+		return "", nil, err
 	}
 	for index := len(payload.Messages) - 1; index >= 0; index-- {
 		message := payload.Messages[index]
@@ -199,15 +313,15 @@ func extractTranslationResult(raw json.RawMessage) (string, error) {
 			if msg == "" {
 				msg = "unknown error"
 			}
-			return "", fmt.Errorf("pi error: %s", msg)
+			return "", message.Usage, fmt.Errorf("pi error: %s", msg)
 		}
 		text, err := extractContentText(message.Content)
 		if err != nil {
-			return "", err
+			return "", message.Usage, err
 		}
-		return text, nil
+		return text, message.Usage, nil
 	}
-	return "", errors.New("assistant message not found")
+	return "", nil, errors.New("assistant message not found")
 }
 
 func extractContentText(content json.RawMessage) (string, error) {
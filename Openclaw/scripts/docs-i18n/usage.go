@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pi "github.com/joshp123/pi-golang"
+)
+
+// tokenUsage is what one or more model calls consumed. Cost is in dollars as
+// reported by the provider; backends that don't report it leave it at 0.
+type tokenUsage struct {
+	Calls      int     `json:"calls"`
+	Input      int     `json:"input"`
+	Output     int     `json:"output"`
+	CacheRead  int     `json:"cache_read"`
+	CacheWrite int     `json:"cache_write"`
+	Cost       float64 `json:"cost"`
+}
+
+func (u *tokenUsage) add(other tokenUsage) {
+	u.Calls += other.Calls
+	u.Input += other.Input
+	u.Output += other.Output
+	u.CacheRead += other.CacheRead
+	u.CacheWrite += other.CacheWrite
+	u.Cost += other.Cost
+}
+
+// Tokens is every token billed, cached ones included.
+func (u tokenUsage) Tokens() int {
+	return u.Input + u.Output + u.CacheRead + u.CacheWrite
+}
+
+func (u tokenUsage) String() string {
+	return fmt.Sprintf("calls=%d in=%d out=%d cache_read=%d cache_write=%d cost=$%.4f", u.Calls, u.Input, u.Output, u.CacheRead, u.CacheWrite, u.Cost)
+}
+
+// Brief is the usage for a per-file log line.
+func (u tokenUsage) Brief() string {
+	return fmt.Sprintf("%d calls, %d tokens, $%.4f", u.Calls, u.Tokens(), u.Cost)
+}
+
+func piUsage(usage *pi.Usage) tokenUsage {
+	if usage == nil {
+		return tokenUsage{Calls: 1}
+	}
+	converted := tokenUsage{
+		Calls:      1,
+		Input:      usage.Input,
+		Output:     usage.Output,
+		CacheRead:  usage.CacheRead,
+		CacheWrite: usage.CacheWrite,
+	}
+	if usage.Cost != nil {
+		converted.Cost = usage.Cost.Total
+	}
+	return converted
+}
+
+// usageMeter collects the usage of the model calls made for one file. It is
+// shared by the chunks of a doc translated in parallel.
+type usageMeter struct {
+	mu    sync.Mutex
+	usage tokenUsage
+}
+
+func (m *usageMeter) Usage() tokenUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+type usageKey struct{}
+
+// withUsageMeter makes the model calls made with ctx report to meter.
+func withUsageMeter(ctx context.Context, meter *usageMeter) context.Context {
+	return context.WithValue(ctx, usageKey{}, meter)
+}
+
+// recordUsage adds one call's usage to the meter attached to ctx, if any.
+func recordUsage(ctx context.Context, usage tokenUsage) {
+	meter, _ := ctx.Value(usageKey{}).(*usageMeter)
+	if meter == nil {
+		return
+	}
+	meter.mu.Lock()
+	meter.usage.add(usage)
+	meter.mu.Unlock()
+}
+
+// runBudget caps a run; zero fields are unlimited.
+type runBudget struct {
+	Tokens int
+	Cost   float64
+}
+
+// parseBudget parses -budget: a token count (with an optional k or M
+// suffix), a dollar amount ("$20"), or both separated by a comma.
+func parseBudget(value string) (runBudget, error) {
+	var budget runBudget
+	for _, part := range splitList(value) {
+		if dollars, ok := strings.CutPrefix(part, "$"); ok {
+			cost, err := strconv.ParseFloat(dollars, 64)
+			if err != nil || cost <= 0 {
+				return runBudget{}, fmt.Errorf("invalid budget %q", part)
+			}
+			budget.Cost = cost
+			continue
+		}
+		multiplier := 1.0
+		number := part
+		switch {
+		case strings.HasSuffix(part, "k"):
+			multiplier, number = 1e3, strings.TrimSuffix(part, "k")
+		case strings.HasSuffix(part, "M"):
+			multiplier, number = 1e6, strings.TrimSuffix(part, "M")
+		}
+		tokens, err := strconv.ParseFloat(number, 64)
+		if err != nil || tokens <= 0 {
+			return runBudget{}, fmt.Errorf("invalid budget %q", part)
+		}
+		budget.Tokens = int(tokens * multiplier)
+	}
+	return budget, nil
+}
+
+// checkProvider rejects a dollar budget for a provider that reports no cost,
+// which would never run out. Only pi prices its calls.
+func (b runBudget) checkProvider(provider string) error {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "", "pi":
+		return nil
+	}
+	if b.Cost > 0 {
+		return fmt.Errorf("budget $%.2f: the %s provider reports no cost; give a token budget instead", b.Cost, provider)
+	}
+	return nil
+}
+
+func (b runBudget) String() string {
+	var parts []string
+	if b.Tokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", b.Tokens))
+	}
+	if b.Cost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f", b.Cost))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (b runBudget) exceeded(usage tokenUsage) bool {
+	return b.Tokens > 0 && usage.Tokens() >= b.Tokens || b.Cost > 0 && usage.Cost >= b.Cost
+}
+
+type fileUsage struct {
+	Lang    string     `json:"lang"`
+	Path    string     `json:"path"`
+	Skipped bool       `json:"skipped,omitempty"`
+	Usage   tokenUsage `json:"usage"`
+}
+
+// usageLedger aggregates usage per file and per run, and tells the runners
+// when the budget is spent. A nil ledger tracks nothing.
+type usageLedger struct {
+	budget runBudget
+
+	mu    sync.Mutex
+	total tokenUsage
+	files []fileUsage
+}
+
+func newUsageLedger(budget runBudget) *usageLedger {
+	return &usageLedger{budget: budget}
+}
+
+// track returns the context to translate relPath into lang with and a
+// function that records the job's usage once it is done.
+func (l *usageLedger) track(ctx context.Context, lang, relPath string) (context.Context, func(skipped bool) tokenUsage) {
+	meter := &usageMeter{}
+	return withUsageMeter(ctx, meter), func(skipped bool) tokenUsage {
+		usage := meter.Usage()
+		if l != nil {
+			l.mu.Lock()
+			l.total.add(usage)
+			l.files = append(l.files, fileUsage{Lang: lang, Path: relPath, Skipped: skipped, Usage: usage})
+			l.mu.Unlock()
+		}
+		return usage
+	}
+}
+
+// Exhausted reports whether the run has spent its budget, in which case no
+// new file should be started.
+func (l *usageLedger) Exhausted() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.budget.exceeded(l.total)
+}
+
+func (l *usageLedger) Total() tokenUsage {
+	if l == nil {
+		return tokenUsage{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// usageReport is the -report file.
+type usageReport struct {
+	Langs           []string    `json:"langs"`
+	Mode            string      `json:"mode"`
+	Provider        string      `json:"provider"`
+	Model           string      `json:"model"`
+	Processed       int         `json:"processed"`
+	Skipped         int         `json:"skipped"`
+	Remaining       int         `json:"remaining"`
+	BudgetExhausted bool        `json:"budget_exhausted"`
+	ElapsedSeconds  float64     `json:"elapsed_seconds"`
+	Usage           tokenUsage  `json:"usage"`
+	Files           []fileUsage `json:"files"`
+}
+
+// Report summarizes the run with the most expensive jobs first.
+func (l *usageLedger) Report(langs []string, mode string, translator Translator, processed, skipped, remaining int, elapsed time.Duration) usageReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	files := append([]fileUsage(nil), l.files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Usage.Cost != files[j].Usage.Cost {
+			return files[i].Usage.Cost > files[j].Usage.Cost
+		}
+		if files[i].Usage.Tokens() != files[j].Usage.Tokens() {
+			return files[i].Usage.Tokens() > files[j].Usage.Tokens()
+		}
+		if files[i].Path != files[j].Path {
+			return files[i].Path < files[j].Path
+		}
+		return files[i].Lang < files[j].Lang
+	})
+	return usageReport{
+		Langs:           langs,
+		Mode:            mode,
+		Provider:        translator.Provider(),
+		Model:           translator.Model(),
+		Processed:       processed,
+		Skipped:         skipped,
+		Remaining:       remaining,
+		BudgetExhausted: l.budget.exceeded(l.total),
+		ElapsedSeconds:  elapsed.Seconds(),
+		Usage:           l.total,
+		Files:           files,
+	}
+}
+
+func writeUsageReport(path string, report usageReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractTranslationResultUsage(t *testing.T) {
+	raw := json.RawMessage(`{"type":"agent_end","messages":[{"role":"user","content":"hi"},{"role":"assistant","content":[{"type":"text","text":"你好"}],"usage":{"input":1200,"output":300,"cacheRead":4000,"cacheWrite":0,"cost":{"total":0.0215}}}]}`)
+	text, usage, err := extractTranslationResult(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := piUsage(usage)
+	want := tokenUsage{Calls: 1, Input: 1200, Output: 300, CacheRead: 4000, Cost: 0.0215}
+	if text != "你好" || got != want {
+		t.Fatalf("text = %q, usage = %+v, want %+v", text, got, want)
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	tests := map[string]runBudget{
+		"":          {},
+		"500k":      {Tokens: 500_000},
+		"2M":        {Tokens: 2_000_000},
+		"$12.5":     {Cost: 12.5},
+		"1.5M, $20": {Tokens: 1_500_000, Cost: 20},
+	}
+	for value, want := range tests {
+		got, err := parseBudget(value)
+		if err != nil || got != want {
+			t.Errorf("parseBudget(%q) = %+v, %v, want %+v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"lots", "$", "-5"} {
+		if _, err := parseBudget(value); err == nil {
+			t.Errorf("parseBudget(%q) succeeded", value)
+		}
+	}
+}
+
+func TestBudgetNeedsCostReportingProvider(t *testing.T) {
+	if err := (runBudget{Cost: 20}).checkProvider("pi"); err != nil {
+		t.Errorf("pi: %v", err)
+	}
+	if err := (runBudget{Tokens: 1000}).checkProvider("openai"); err != nil {
+		t.Errorf("token budget with openai: %v", err)
+	}
+	for _, provider := range []string{"openai", "fake"} {
+		if err := (runBudget{Tokens: 1000, Cost: 20}).checkProvider(provider); err == nil {
+			t.Errorf("dollar budget with %s accepted", provider)
+		}
+	}
+}
+
+func TestRunDocSequentialStopsAtBudget(t *testing.T) {
+	docsRoot := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		files = append(files, filepath.Join(docsRoot, name))
+	}
+	// Every file costs 600 tokens; the budget is spent after the second.
+	process := func(ctx context.Context, translator Translator, docsRoot, filePath, srcLang, tgtLang string, overwrite bool) (bool, error) {
+		recordUsage(ctx, tokenUsage{Calls: 2, Input: 500, Output: 100, Cost: 0.01})
+		return false, nil
+	}
+	ledger := newUsageLedger(runBudget{Tokens: 1000})
+	target := &langTarget{Lang: "zh-CN", Process: process, Queue: files}
+	translators := translatorPool{"zh-CN": NewFakeTranslator()}
+	processed, skipped, err := runDocSequential(context.Background(), buildJobs(docsRoot, files, []*langTarget{target}), translators, docsRoot, "en", false, ledger, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed != 2 || skipped != 0 || !ledger.Exhausted() {
+		t.Fatalf("processed = %d, skipped = %d, exhausted = %t", processed, skipped, ledger.Exhausted())
+	}
+
+	report := ledger.Report([]string{"zh-CN"}, "segment", NewFakeTranslator(), processed, skipped, len(files)-processed, 0)
+	if len(report.Files) != 2 || report.Files[0].Path != "a.md" || report.Files[0].Usage.Calls != 2 {
+		t.Fatalf("files = %+v", report.Files)
+	}
+	if report.Usage.Tokens() != 1200 || report.Remaining != 1 || !report.BudgetExhausted {
+		t.Fatalf("report = %+v", report)
+	}
+}
@@ -9,14 +9,20 @@ import (
 	"strings"
 )
 
-const (
-	workflowVersion = 15
-	providerName    = "pi"
-	modelVersion    = "claude-opus-4-6"
-)
+const workflowVersion = 15
+
+func cacheNamespace(provider, model, profile string) string {
+	return cacheNamespaceVersion(workflowVersion, provider, model, profile)
+}
 
-func cacheNamespace() string {
-	return fmt.Sprintf("wf=%d|provider=%s|model=%s", workflowVersion, providerName, modelVersion)
+// cacheNamespaceVersion includes the prompt profile hash only for non-default
+// profiles, so entries cached with the built-in prompts keep their keys.
+func cacheNamespaceVersion(version int, provider, model, profile string) string {
+	namespace := fmt.Sprintf("wf=%d|provider=%s|model=%s", version, provider, model)
+	if profile != "" {
+		namespace += "|profile=" + profile
+	}
+	return namespace
 }
 
 func cacheKey(namespace, srcLang, tgtLang, segmentID, textHash string) string {